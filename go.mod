@@ -1,6 +1,6 @@
 module mtoohey.com/q
 
-go 1.18
+go 1.24.0
 
 require (
 	github.com/adrg/xdg v0.4.0 // MIT
@@ -10,6 +10,8 @@ require (
 	github.com/gdamore/tcell/v2 v2.6.0 // Apache-2.0
 	github.com/lithammer/fuzzysearch v1.1.5 // MIT
 	github.com/mattn/go-runewidth v0.0.14 // MIT
+	github.com/mewkiz/flac v1.0.7 // Unlicense
+	github.com/pion/opus v0.1.0 // MIT
 	golang.org/x/exp v0.0.0-20221217163422-3c43f8badb15 // BSD-3-Clause
 )
 
@@ -21,7 +23,6 @@ require (
 	github.com/jfreymuth/oggvorbis v1.0.1 // indirect
 	github.com/jfreymuth/vorbis v1.0.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mewkiz/flac v1.0.7 // indirect
 	github.com/mewkiz/pkg v0.0.0-20190919212034-518ade7978e2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
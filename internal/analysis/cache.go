@@ -0,0 +1,214 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"mtoohey.com/q/internal/track"
+
+	"github.com/adrg/xdg"
+)
+
+// cacheEntry is the cached result of analyzing a file.
+type cacheEntry struct {
+	// ModTime is the modification time of the file when it was analyzed, in
+	// nanoseconds since the Unix epoch. The entry is stale if the file has
+	// been modified since.
+	ModTime int64 `json:"mtime"`
+	Result
+}
+
+// analysisCall is an analysis that is in progress.
+type analysisCall struct {
+	done   chan struct{}
+	result Result
+	err    error
+}
+
+// Cache stores the results of analyses in a file, keyed by the absolute path
+// and modification time of the analyzed file. It is safe for concurrent use.
+type Cache struct {
+	path string
+
+	// mu protects entries, calls, and dirty.
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// calls contains the analyses that are in progress, so that concurrent
+	// requests for the same file only analyze it once.
+	calls map[string]*analysisCall
+	// dirty indicates whether entries contains results that haven't been
+	// saved.
+	dirty bool
+}
+
+// OpenCache loads the cache file at path, or at a default location in the XDG
+// cache directory if path is nil. The file doesn't have to exist yet.
+func OpenCache(path *string) (*Cache, error) {
+	var p string
+	if path != nil {
+		p = *path
+	} else {
+		var err error
+		p, err = xdg.CacheFile(filepath.Join("q", "loudness.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve cache path: %w", err)
+		}
+	}
+
+	entries, err := readCacheFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		path:    p,
+		entries: entries,
+		calls:   map[string]*analysisCall{},
+	}, nil
+}
+
+func readCacheFile(path string) (map[string]cacheEntry, error) {
+	entries := map[string]cacheEntry{}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entries, nil
+		}
+
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// stat returns the absolute version of path, and the modification time of the
+// file it refers to.
+func stat(path string) (string, int64, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return abs, info.ModTime().UnixNano(), nil
+}
+
+// Get returns the cached result for the file at path, if there is one and the
+// file hasn't been modified since it was analyzed.
+func (c *Cache) Get(path string) (Result, bool) {
+	abs, modTime, err := stat(path)
+	if err != nil {
+		return Result{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[abs]
+	if !ok || e.ModTime != modTime {
+		return Result{}, false
+	}
+
+	return e.Result, true
+}
+
+// Analyze returns the cached result for t, analyzing it and storing the
+// result if there isn't one yet, or if its silence wasn't detected with
+// silenceThreshold.
+func (c *Cache) Analyze(t *track.Track, silenceThreshold float64) (Result, error) {
+	abs, modTime, err := stat(t.Path)
+	if err != nil {
+		return Result{}, fmt.Errorf("stat failed: %w", err)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[abs]; ok && e.ModTime == modTime && e.HasSilence(silenceThreshold) {
+		c.mu.Unlock()
+		return e.Result, nil
+	}
+	if call, ok := c.calls[abs]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.result, call.err
+	}
+	call := &analysisCall{done: make(chan struct{})}
+	c.calls[abs] = call
+	c.mu.Unlock()
+
+	call.result, call.err = Analyze(t, silenceThreshold)
+
+	c.mu.Lock()
+	delete(c.calls, abs)
+	if call.err == nil {
+		c.entries[abs] = cacheEntry{ModTime: modTime, Result: call.result}
+		c.dirty = true
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.result, call.err
+}
+
+// Save writes any new results to the cache file. Results that were written to
+// the file by others since it was loaded are kept.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	entries, err := readCacheFile(c.path)
+	if err != nil {
+		return err
+	}
+	for path, e := range entries {
+		if _, ok := c.entries[path]; !ok {
+			c.entries[path] = e
+		}
+	}
+
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+
+	// write to a temporary file first so that readers never see a partially
+	// written cache
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cache file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }() // fails once renamed, which is fine
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(f.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to replace cache file: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
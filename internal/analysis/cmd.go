@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+
+	"mtoohey.com/q/internal/cmd"
+	"mtoohey.com/q/internal/query"
+	"mtoohey.com/q/internal/track"
+)
+
+// saveInterval is the number of songs after which the cache is saved while
+// analyzing, so that progress isn't lost if analysis is interrupted.
+const saveInterval = 100
+
+type Cmd struct {
+	// Jobs is the number of songs to analyze in parallel.
+	Jobs int `short:"j" default:"0" help:"Number of songs to analyze in parallel. Defaults to the number of CPUs."`
+	// Tagged indicates whether songs with ReplayGain tags should be analyzed
+	// too.
+	Tagged bool `help:"Also analyze songs that have ReplayGain tags."`
+	// SilenceThreshold is the level below which leading and trailing
+	// samples are considered silent. It should match the server's so that
+	// the server can use the results.
+	SilenceThreshold float64 `default:"-60" help:"Level in dBFS below which leading and trailing samples are considered silent. Should match the server's."`
+
+	// Queries are queries whose results will be analyzed.
+	Queries []string `arg:"" help:"Queries whose results will be analyzed."`
+}
+
+// analyzed is the outcome of analyzing a single song.
+type analyzed struct {
+	path   string
+	result Result
+	err    error
+}
+
+func (c Cmd) Run(g cmd.Globals) error {
+	cache, err := OpenCache(g.LoudnessCache)
+	if err != nil {
+		return err
+	}
+
+	pathSet := map[string]struct{}{}
+	paths := []string{}
+	for _, q := range c.Queries {
+		newPaths, err := query.Query(g.MusicDir, q)
+		if err != nil {
+			return fmt.Errorf(`failed to execute query "%s": %w`, q, err)
+		}
+
+		for _, p := range newPaths {
+			if _, ok := pathSet[p]; !ok {
+				paths = append(paths, p)
+				pathSet[p] = struct{}{}
+			}
+		}
+	}
+
+	jobs := c.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	pathCh := make(chan string)
+	analyzedCh := make(chan analyzed)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pathCh {
+				if a, ok := c.analyze(cache, p); ok {
+					analyzedCh <- a
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, p := range paths {
+			pathCh <- p
+		}
+		close(pathCh)
+		wg.Wait()
+		close(analyzedCh)
+	}()
+
+	var n, failed int
+	for a := range analyzedCh {
+		if a.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", a.path, a.err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s: %.2f LUFS, %.2f dBTP\n", a.path, a.result.Loudness, 20*math.Log10(a.result.Peak))
+
+		if n++; n%saveInterval == 0 {
+			if err := cache.Save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := cache.Save(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to analyze %d songs", failed)
+	}
+	return nil
+}
+
+// analyze analyzes the song at path, returning false if it should be skipped
+// because it isn't a song, or because it has ReplayGain tags and c.Tagged is
+// false.
+func (c Cmd) analyze(cache *Cache, path string) (analyzed, bool) {
+	t := &track.Track{Path: path}
+
+	rg, err := t.ReplayGain()
+	if track.IsUnknownFormat(err) {
+		return analyzed{}, false
+	}
+	if rg != nil && !c.Tagged {
+		return analyzed{}, false
+	}
+
+	r, err := cache.Analyze(t, c.SilenceThreshold)
+	return analyzed{path: path, result: r, err: err}, true
+}
@@ -0,0 +1,286 @@
+// Package analysis measures the loudness of tracks according to EBU R128, see
+// https://tech.ebu.ch/docs/r/r128.pdf and
+// https://www.itu.int/rec/R-REC-BS.1770, and detects their leading and
+// trailing silence.
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"mtoohey.com/q/internal/track"
+	"mtoohey.com/q/internal/util"
+
+	"github.com/faiface/beep"
+)
+
+// Result contains the results of analyzing a track.
+type Result struct {
+	// Loudness is the integrated loudness in LUFS. It is -70 LUFS, the
+	// absolute gate, if the track is silent.
+	Loudness float64 `json:"loudness"`
+	// Peak is the true peak, where 1 is full scale.
+	Peak float64 `json:"peak"`
+	// Silence is the leading and trailing silence of the track. It is nil in
+	// results that were cached before silence was detected.
+	Silence *Silence `json:"silence,omitempty"`
+}
+
+// ReplayGain returns the ReplayGain values equivalent to r.
+func (r Result) ReplayGain() *track.ReplayGainValues {
+	return &track.ReplayGainValues{Gain: referenceLoudness - r.Loudness, Peak: r.Peak}
+}
+
+const (
+	// referenceLoudness is the loudness in LUFS that ReplayGain 2.0 values
+	// normalize to.
+	referenceLoudness = -18
+	// absoluteGate is the loudness in LUFS below which blocks are ignored.
+	absoluteGate = -70
+	// relativeGate is the loudness in LU relative to the loudness of the
+	// blocks above the absolute gate below which blocks are ignored.
+	relativeGate = -10
+	// blockSteps is the number of steps in each 400ms gating block, so
+	// consecutive blocks overlap by 75%.
+	blockSteps = 4
+)
+
+// Analyze decodes t, measures its loudness, and detects its silence below
+// silenceThreshold dBFS.
+func Analyze(t *track.Track, silenceThreshold float64) (Result, error) {
+	streamer, format, err := t.Decode()
+	if err != nil {
+		return Result{}, fmt.Errorf("decode failed: %w", err)
+	}
+	defer func() { _ = streamer.Close() }() // intentionally ignore close error
+
+	m := newMeter(format)
+	d := newSilenceDetector(format.SampleRate, silenceThreshold)
+	samples := make([][2]float64, 4096)
+	for {
+		n, ok := streamer.Stream(samples)
+		m.write(samples[:n])
+		d.write(samples[:n])
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return Result{}, fmt.Errorf("stream failed: %w", err)
+	}
+
+	r := m.result()
+	r.Silence = d.result()
+	return r, nil
+}
+
+// biquad is a second order IIR filter in direct form I.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	// x and y contain the previous two inputs and outputs.
+	x, y [2]float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x[0] + f.b2*f.x[1] - f.a1*f.y[0] - f.a2*f.y[1]
+	f.x = [2]float64{x, f.x[0]}
+	f.y = [2]float64{y, f.y[0]}
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter, a high shelf
+// that models the acoustic effects of the head, followed by a high pass. The
+// coefficients are derived for the given sample rate from the analog
+// prototypes, since BS.1770 only lists them for 48kHz.
+func kWeighting(sampleRate beep.SampleRate) [2]biquad {
+	fs := float64(sampleRate)
+
+	const (
+		shelfFreq = 1681.974450955533
+		shelfGain = 3.999843853973347
+		shelfQ    = 0.7071752369554196
+	)
+	k := math.Tan(math.Pi * shelfFreq / fs)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	const (
+		highPassFreq = 38.13547087602444
+		highPassQ    = 0.5003270373238773
+	)
+	k = math.Tan(math.Pi * highPassFreq / fs)
+	a0 = 1 + k/highPassQ + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/highPassQ + k*k) / a0,
+	}
+
+	return [2]biquad{shelf, highPass}
+}
+
+const (
+	// oversampling is the factor by which the signal is oversampled to
+	// measure the true peak.
+	oversampling = 4
+	// interpolationTaps is the number of input samples each interpolated
+	// sample depends on.
+	interpolationTaps = 12
+)
+
+// interpolationFilter contains the coefficients of the Hann windowed sinc
+// filter used to interpolate the samples between each pair of input samples,
+// with a row for each fractional offset.
+var interpolationFilter = func() (f [oversampling - 1][interpolationTaps]float64) {
+	const half = interpolationTaps / 2
+	for p := range f {
+		for j := range f[p] {
+			// the distance from the interpolated sample, which lies p+1
+			// quarters of the way between the middle two input samples
+			d := float64(half-1-j) + float64(p+1)/oversampling
+			f[p][j] = sinc(d) * (0.5 + 0.5*math.Cos(math.Pi*d/half))
+		}
+	}
+	return f
+}()
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// meter measures the loudness of the samples written to it.
+type meter struct {
+	channels int
+	filters  [2][2]biquad
+
+	// stepLen is the number of samples in each 100ms step.
+	stepLen int
+	// stepN is the number of samples written to the current step.
+	stepN int
+	// stepSum contains the sums of the squares of the filtered samples in
+	// the current step, for each channel.
+	stepSum [2]float64
+	// steps contains the mean squares of the last blockSteps-1 complete
+	// steps, which form a block with the current step once it's complete.
+	steps [][2]float64
+	// blocks contains the power of each gating block above the absolute
+	// gate.
+	blocks []float64
+
+	// history contains the last interpolationTaps unfiltered samples of each
+	// channel, most recent last, for true peak measurement.
+	history [2][interpolationTaps]float64
+	peak    float64
+}
+
+func newMeter(format beep.Format) *meter {
+	m := &meter{
+		channels: util.Clamp(1, format.NumChannels, 2),
+		stepLen:  util.Max(format.SampleRate.N(100*time.Millisecond), 1),
+	}
+	m.filters[0] = kWeighting(format.SampleRate)
+	m.filters[1] = kWeighting(format.SampleRate)
+	return m
+}
+
+func (m *meter) write(samples [][2]float64) {
+	for _, s := range samples {
+		for c := 0; c < m.channels; c++ {
+			m.writePeak(c, s[c])
+
+			y := m.filters[c][1].process(m.filters[c][0].process(s[c]))
+			m.stepSum[c] += y * y
+		}
+
+		if m.stepN++; m.stepN == m.stepLen {
+			m.completeStep()
+		}
+	}
+}
+
+func (m *meter) writePeak(c int, x float64) {
+	h := &m.history[c]
+	copy(h[:], h[1:])
+	h[interpolationTaps-1] = x
+
+	m.peak = util.Max(m.peak, math.Abs(x))
+	for _, coeffs := range interpolationFilter {
+		var y float64
+		for j, coeff := range coeffs {
+			y += coeff * h[j]
+		}
+		m.peak = util.Max(m.peak, math.Abs(y))
+	}
+}
+
+func (m *meter) completeStep() {
+	var step [2]float64
+	for c := range step {
+		step[c] = m.stepSum[c] / float64(m.stepLen)
+	}
+	m.stepN, m.stepSum = 0, [2]float64{}
+
+	if len(m.steps) == blockSteps-1 {
+		// all channels have a weight of 1, since only the left and right
+		// channels are supported
+		power := step[0] + step[1]
+		for _, s := range m.steps {
+			power += s[0] + s[1]
+		}
+		power /= blockSteps
+
+		if loudness(power) > absoluteGate {
+			m.blocks = append(m.blocks, power)
+		}
+
+		m.steps = m.steps[1:]
+	}
+	m.steps = append(m.steps, step)
+}
+
+func (m *meter) result() Result {
+	integrated := float64(absoluteGate)
+	if mean := meanPower(m.blocks, math.Inf(-1)); mean > 0 {
+		if mean := meanPower(m.blocks, loudness(mean)+relativeGate); mean > 0 {
+			integrated = loudness(mean)
+		}
+	}
+
+	return Result{Loudness: integrated, Peak: m.peak}
+}
+
+// meanPower returns the mean of the powers with a loudness above gate, or 0 if
+// there aren't any.
+func meanPower(powers []float64, gate float64) float64 {
+	var sum float64
+	var n int
+	for _, p := range powers {
+		if loudness(p) > gate {
+			sum += p
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// loudness converts the power of a block to LUFS.
+func loudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
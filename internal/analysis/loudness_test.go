@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+
+	"github.com/faiface/beep"
+)
+
+// measure returns the result of metering seconds of a sine wave with the given
+// frequency, amplitude, and phase.
+func measure(format beep.Format, freq, amplitude, phase float64, seconds int) Result {
+	m := newMeter(format)
+	samples := make([][2]float64, int(format.SampleRate)*seconds)
+	for i := range samples {
+		x := amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(format.SampleRate)+phase)
+		samples[i] = [2]float64{x, x}
+	}
+	m.write(samples)
+	return m.result()
+}
+
+func TestMeter(t *testing.T) {
+	// BS.1770 specifies that a 997Hz sine wave at 0dBFS in one channel
+	// measures -3.01 LUFS
+	t.Run("stereo", func(t *testing.T) {
+		r := measure(beep.Format{SampleRate: 48000, NumChannels: 2}, 997, 0.1, 0, 5)
+		assert.True(t, math.Abs(r.Loudness+20) < 0.05)
+		assert.True(t, math.Abs(r.Peak-0.1) < 0.001)
+	})
+
+	t.Run("mono", func(t *testing.T) {
+		r := measure(beep.Format{SampleRate: 44100, NumChannels: 1}, 997, 0.1, 0, 5)
+		assert.True(t, math.Abs(r.Loudness+23.01) < 0.05)
+	})
+
+	t.Run("true peak", func(t *testing.T) {
+		// every sample is at ±0.5, but the peaks lie between them
+		r := measure(beep.Format{SampleRate: 48000, NumChannels: 2}, 12000, 0.5/math.Sin(math.Pi/4), math.Pi/4, 1)
+		assert.True(t, math.Abs(r.Peak-0.5/math.Sin(math.Pi/4)) < 0.01)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		r := measure(beep.Format{SampleRate: 48000, NumChannels: 2}, 997, 0, 0, 1)
+		assert.Equal(t, Result{Loudness: absoluteGate}, r)
+	})
+}
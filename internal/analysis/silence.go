@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// Silence contains the positions where the leading and trailing silence of a
+// track end and begin.
+type Silence struct {
+	// Threshold is the level in dBFS below which samples were considered
+	// silent.
+	Threshold float64 `json:"threshold"`
+	// Start is the position in seconds of the first sample above the
+	// threshold, and End is the position in seconds just after the last
+	// one. Both are 0 if the track is silent.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// silenceDetector finds the first and last samples written to it that are
+// above a threshold.
+type silenceDetector struct {
+	sampleRate beep.SampleRate
+	threshold  float64
+	// level is threshold converted to a linear amplitude.
+	level float64
+
+	// n is the number of samples written so far, and first and last are the
+	// indices of the first and last samples above the threshold, or -1 if
+	// there aren't any.
+	n, first, last int
+}
+
+func newSilenceDetector(sampleRate beep.SampleRate, threshold float64) *silenceDetector {
+	return &silenceDetector{
+		sampleRate: sampleRate,
+		threshold:  threshold,
+		level:      math.Pow(10, threshold/20),
+		first:      -1,
+		last:       -1,
+	}
+}
+
+func (d *silenceDetector) write(samples [][2]float64) {
+	for _, s := range samples {
+		if math.Abs(s[0]) > d.level || math.Abs(s[1]) > d.level {
+			if d.first < 0 {
+				d.first = d.n
+			}
+			d.last = d.n
+		}
+		d.n++
+	}
+}
+
+func (d *silenceDetector) result() *Silence {
+	s := &Silence{Threshold: d.threshold}
+	if d.first >= 0 {
+		s.Start = d.sampleRate.D(d.first).Seconds()
+		s.End = d.sampleRate.D(d.last + 1).Seconds()
+	}
+	return s
+}
+
+// HasSilence returns whether r contains the silence detected with threshold.
+func (r Result) HasSilence(threshold float64) bool {
+	return r.Silence != nil && r.Silence.Threshold == threshold
+}
@@ -0,0 +1,27 @@
+package analysis
+
+import (
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestSilenceDetector(t *testing.T) {
+	t.Run("leading and trailing", func(t *testing.T) {
+		d := newSilenceDetector(1000, -60)
+		samples := make([][2]float64, 1000)
+		for i := 250; i < 500; i++ {
+			// below the threshold, but not in the other channel
+			samples[i] = [2]float64{0.0001, 0.01}
+		}
+		d.write(samples[:400])
+		d.write(samples[400:])
+		assert.Equal(t, &Silence{Threshold: -60, Start: 0.25, End: 0.5}, d.result())
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		d := newSilenceDetector(1000, -60)
+		d.write([][2]float64{{0.0001, -0.0001}})
+		assert.Equal(t, &Silence{Threshold: -60}, d.result())
+	})
+}
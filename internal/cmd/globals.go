@@ -12,4 +12,8 @@ type Globals struct {
 	// UnixSocket is the path of the socket to bind or connect to, depending on
 	// the command. No socket is used if this flag is not provided.
 	UnixSocket *string `short:"u" help:"The path of the socket to bind or connect to, depending on the command. No socket is used if this flag is not provided."`
+	// LoudnessCache is the path of the file that stores the results of
+	// loudness analysis. A file in the XDG cache directory is used if this flag
+	// is not provided.
+	LoudnessCache *string `help:"The path of the file that stores the results of loudness analysis. A file in the XDG cache directory is used if this flag is not provided."`
 }
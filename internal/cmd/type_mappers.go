@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"time"
 
 	"mtoohey.com/q/internal/protocol"
 
@@ -56,4 +58,89 @@ var TypeMappers = []kong.Option{
 
 		return nil
 	})),
+
+	kong.TypeMapper(reflect.TypeOf(protocol.ReplayGainMode(0)), kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+		var modeString string
+		if err := ctx.Scan.PopValueInto("string", &modeString); err != nil {
+			return err
+		}
+
+		switch modeString {
+		case "off":
+			target.Set(reflect.ValueOf(protocol.ReplayGainModeOff))
+		case "track":
+			target.Set(reflect.ValueOf(protocol.ReplayGainModeTrack))
+		case "album":
+			target.Set(reflect.ValueOf(protocol.ReplayGainModeAlbum))
+		case "auto":
+			target.Set(reflect.ValueOf(protocol.ReplayGainModeAuto))
+		default:
+			return fmt.Errorf(`must be one of "off","track","album","auto" but got "%s"`, modeString)
+		}
+
+		return nil
+	})),
+
+	kong.TypeMapper(reflect.TypeOf(protocol.CrossfadeState(0)), kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+		var durationString string
+		if err := ctx.Scan.PopValueInto("string", &durationString); err != nil {
+			return err
+		}
+
+		d, err := time.ParseDuration(durationString)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf(`must not be negative but got "%s"`, durationString)
+		}
+
+		target.Set(reflect.ValueOf(protocol.CrossfadeState(d)))
+		return nil
+	})),
+
+	kong.TypeMapper(reflect.TypeOf(protocol.VolumeState(0)), kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+		var volumeString string
+		if err := ctx.Scan.PopValueInto("string", &volumeString); err != nil {
+			return err
+		}
+
+		v, err := strconv.ParseUint(volumeString, 10, 8)
+		if err != nil || protocol.VolumeState(v) > protocol.MaxVolume {
+			return fmt.Errorf(`must be between 0 and %d but got "%s"`, protocol.MaxVolume, volumeString)
+		}
+
+		target.Set(reflect.ValueOf(protocol.VolumeState(v)))
+		return nil
+	})),
+
+	kong.TypeMapper(reflect.TypeOf(protocol.SpeedState(0)), kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+		var speedString string
+		if err := ctx.Scan.PopValueInto("string", &speedString); err != nil {
+			return err
+		}
+
+		v, err := strconv.ParseFloat(speedString, 64)
+		if err != nil || protocol.SpeedState(v) < protocol.MinSpeed || protocol.SpeedState(v) > protocol.MaxSpeed {
+			return fmt.Errorf(`must be between %g and %g but got "%s"`, protocol.MinSpeed, protocol.MaxSpeed, speedString)
+		}
+
+		target.Set(reflect.ValueOf(protocol.SpeedState(v)))
+		return nil
+	})),
+
+	kong.TypeMapper(reflect.TypeOf(protocol.BalanceState(0)), kong.MapperFunc(func(ctx *kong.DecodeContext, target reflect.Value) error {
+		var balanceString string
+		if err := ctx.Scan.PopValueInto("string", &balanceString); err != nil {
+			return err
+		}
+
+		v, err := strconv.ParseInt(balanceString, 10, 8)
+		if err != nil || v < -int64(protocol.MaxBalance) || v > int64(protocol.MaxBalance) {
+			return fmt.Errorf(`must be between %d and %d but got "%s"`, -protocol.MaxBalance, protocol.MaxBalance, balanceString)
+		}
+
+		target.Set(reflect.ValueOf(protocol.BalanceState(v)))
+		return nil
+	})),
 }
@@ -0,0 +1,112 @@
+// Package compressor implements a feed-forward dynamic range compressor
+// followed by a limiter, which reduce the difference in loudness between quiet
+// and loud passages.
+package compressor
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// ceiling is the level in dBFS that the limiter keeps the output below.
+	ceiling = -1
+	// limiterRelease is the time it takes the limiter to recover.
+	limiterRelease = 50 * time.Millisecond
+)
+
+// Settings configure a compressor.
+type Settings struct {
+	// Threshold is the level in dBFS above which the signal is compressed.
+	Threshold float64
+	// Ratio is the factor by which the amount that the signal exceeds the
+	// threshold is reduced.
+	Ratio float64
+	// Attack is the time it takes the compressor to react to the signal
+	// exceeding the threshold.
+	Attack time.Duration
+	// Release is the time it takes the compressor to recover once the signal
+	// falls below the threshold.
+	Release time.Duration
+}
+
+// Compressor compresses samples according to its settings. Makeup gain is
+// applied after compression so that a signal at full scale keeps roughly half
+// of the loudness it loses, making quiet passages louder.
+type Compressor struct {
+	threshold, slope, makeup float64
+	// attack and release are the smoothing coefficients of the compressor's
+	// gain reduction, and limiterRelease is the same for the limiter, which
+	// reacts instantly.
+	attack, release, limiterRelease float64
+
+	// reduction and limiterReduction are the current gain reductions in dB.
+	reduction, limiterReduction float64
+}
+
+// coefficient returns the coefficient of a one-pole smoothing filter that
+// reaches about 63% of a step change in d.
+func coefficient(d time.Duration, sampleRate beep.SampleRate) float64 {
+	n := d.Seconds() * float64(sampleRate)
+	if n <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / n)
+}
+
+// New creates a compressor for samples at sampleRate.
+func New(s Settings, sampleRate beep.SampleRate) *Compressor {
+	slope := 1 - 1/math.Max(s.Ratio, 1)
+	return &Compressor{
+		threshold:      s.Threshold,
+		slope:          slope,
+		makeup:         math.Max(-s.Threshold*slope/2, 0),
+		attack:         coefficient(s.Attack, sampleRate),
+		release:        coefficient(s.Release, sampleRate),
+		limiterRelease: coefficient(limiterRelease, sampleRate),
+	}
+}
+
+// Reset forgets the signal processed so far.
+func (c *Compressor) Reset() {
+	c.reduction, c.limiterReduction = 0, 0
+}
+
+// Process compresses samples in place.
+func (c *Compressor) Process(samples [][2]float64) {
+	for i, s := range samples {
+		// the channels are linked so that the stereo image doesn't shift
+		level := toDB(math.Max(math.Abs(s[0]), math.Abs(s[1])))
+
+		target := math.Max(level-c.threshold, 0) * c.slope
+		coef := c.release
+		if target > c.reduction {
+			coef = c.attack
+		}
+		c.reduction = target + coef*(c.reduction-target)
+
+		gain := c.makeup - c.reduction
+
+		// the limiter reacts instantly so that the ceiling is never exceeded
+		limiterTarget := math.Max(level+gain-ceiling, 0)
+		if limiterTarget > c.limiterReduction {
+			c.limiterReduction = limiterTarget
+		} else {
+			c.limiterReduction = limiterTarget + c.limiterRelease*(c.limiterReduction-limiterTarget)
+		}
+
+		g := fromDB(gain - c.limiterReduction)
+		samples[i] = [2]float64{s[0] * g, s[1] * g}
+	}
+}
+
+func toDB(v float64) float64 {
+	// avoid -Inf for silence
+	return 20 * math.Log10(math.Max(v, 1e-10))
+}
+
+func fromDB(db float64) float64 {
+	return math.Pow(10, db/20)
+}
@@ -0,0 +1,64 @@
+package compressor
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+// peakDB returns the peak level of the second half of the result of
+// compressing a sine wave with the given level, so that the compressor has
+// settled.
+func peakDB(c *Compressor, level float64) float64 {
+	const sampleRate = 44100
+
+	samples := make([][2]float64, sampleRate)
+	for i := range samples {
+		v := fromDB(level) * math.Sin(2*math.Pi*1000*float64(i)/sampleRate)
+		samples[i] = [2]float64{v, v}
+	}
+	c.Process(samples)
+
+	var peak float64
+	for _, s := range samples[sampleRate/2:] {
+		peak = math.Max(peak, math.Abs(s[0]))
+	}
+	return toDB(peak)
+}
+
+func TestCompressor(t *testing.T) {
+	settings := Settings{Threshold: -30, Ratio: 4, Attack: 10 * time.Millisecond, Release: 200 * time.Millisecond}
+
+	for _, c := range []struct {
+		level, expected float64
+	}{
+		// below the threshold, only the makeup gain applies
+		{-40, -40 + 11.25},
+		// 30 dB above the threshold becomes 7.5 dB above it, plus makeup
+		{0, -30 + 7.5 + 11.25},
+	} {
+		t.Run(fmt.Sprintf("%gdBFS", c.level), func(t *testing.T) {
+			// the release smooths the reduction between the peaks of the
+			// wave, so it's a little less than in the steady state
+			assert.True(t, math.Abs(peakDB(New(settings, 44100), c.level)-c.expected) < 1)
+		})
+	}
+
+	// with a slow attack, only the limiter can catch the start of a loud sine
+	settings.Attack = time.Second
+	c := New(settings, 44100)
+	samples := make([][2]float64, 4410)
+	for i := range samples {
+		v := math.Sin(2 * math.Pi * 1000 * float64(i) / 44100)
+		samples[i] = [2]float64{v, -v}
+	}
+	c.Process(samples)
+	var peak float64
+	for _, s := range samples {
+		peak = math.Max(peak, math.Max(math.Abs(s[0]), math.Abs(s[1])))
+	}
+	assert.True(t, toDB(peak) <= ceiling+1e-9)
+}
@@ -0,0 +1,84 @@
+// Package eq implements a parametric equalizer made of peaking filters, see
+// https://www.w3.org/TR/audio-eq-cookbook/.
+package eq
+
+import (
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// Band is a single band of an equalizer, which boosts or cuts frequencies
+// around its center frequency.
+type Band struct {
+	// Frequency is the center frequency in Hz.
+	Frequency float64
+	// Gain is the gain at the center frequency in dB.
+	Gain float64
+	// Q determines the width of the band; higher values affect a narrower
+	// range of frequencies.
+	Q float64
+}
+
+// Preset is a named set of bands.
+type Preset []Band
+
+// Equalizer applies a preset to samples.
+type Equalizer struct {
+	filters []peaking
+}
+
+// New creates an equalizer that applies p to samples at sampleRate. Bands
+// whose frequency isn't below the Nyquist frequency are ignored.
+func New(p Preset, sampleRate beep.SampleRate) *Equalizer {
+	e := &Equalizer{}
+	for _, b := range p {
+		if b.Frequency < float64(sampleRate)/2 {
+			e.filters = append(e.filters, newPeaking(b, sampleRate))
+		}
+	}
+	return e
+}
+
+// Process equalizes samples in place.
+func (e *Equalizer) Process(samples [][2]float64) {
+	for i := range e.filters {
+		f := &e.filters[i]
+		for j := range samples {
+			samples[j] = f.process(samples[j])
+		}
+	}
+}
+
+// peaking is a stereo peaking biquad filter in direct form I.
+type peaking struct {
+	b0, b1, b2, a1, a2 float64
+	// x and y contain the previous two inputs and outputs of each channel.
+	x, y [2][2]float64
+}
+
+func newPeaking(b Band, sampleRate beep.SampleRate) peaking {
+	a := math.Pow(10, b.Gain/40)
+	w0 := 2 * math.Pi * b.Frequency / float64(sampleRate)
+	alpha := math.Sin(w0) / (2 * b.Q)
+	cos := math.Cos(w0)
+
+	a0 := 1 + alpha/a
+	return peaking{
+		b0: (1 + alpha*a) / a0,
+		b1: -2 * cos / a0,
+		b2: (1 - alpha*a) / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha/a) / a0,
+	}
+}
+
+func (f *peaking) process(x [2]float64) [2]float64 {
+	var y [2]float64
+	for c := range x {
+		y[c] = f.b0*x[c] + f.b1*f.x[0][c] + f.b2*f.x[1][c] - f.a1*f.y[0][c] - f.a2*f.y[1][c]
+	}
+	f.x = [2][2]float64{x, f.x[0]}
+	f.y = [2][2]float64{y, f.y[0]}
+	return y
+}
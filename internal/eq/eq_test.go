@@ -0,0 +1,67 @@
+package eq
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestParsePresets(t *testing.T) {
+	presets, err := parsePresets(strings.NewReader(`
+# a comment
+bass 60:6:0.7  250:-2:1
+empty
+`))
+	assert.Zero(t, err)
+
+	expected := map[string]Preset{
+		Flat:    nil,
+		"bass":  {{60, 6, 0.7}, {250, -2, 1}},
+		"empty": {},
+	}
+	assert.Equal(t, expected, presets)
+
+	for _, invalid := range []string{"a 60:6", "a 60:x:1", "a 0:6:1", "a 60:6:0", "a\na", "flat 60:6:1"} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := parsePresets(strings.NewReader(invalid))
+			assert.True(t, err != nil)
+		})
+	}
+}
+
+func TestEqualizer(t *testing.T) {
+	const sampleRate = 44100
+
+	e := New(Preset{{Frequency: 1000, Gain: 6, Q: 1}, {Frequency: 30000, Gain: 6, Q: 1}}, sampleRate)
+	// the band above the Nyquist frequency is ignored
+	assert.Equal(t, 1, len(e.filters))
+
+	for _, c := range []struct {
+		freq, gain float64
+	}{
+		{1000, 6},
+		{50, 0},
+		{15000, 0},
+	} {
+		t.Run(fmt.Sprintf("%gHz", c.freq), func(t *testing.T) {
+			e := New(Preset{{Frequency: 1000, Gain: 6, Q: 1}}, sampleRate)
+
+			samples := make([][2]float64, sampleRate)
+			for i := range samples {
+				v := math.Sin(2 * math.Pi * c.freq * float64(i) / sampleRate)
+				samples[i] = [2]float64{v, -v}
+			}
+			e.Process(samples)
+
+			// skip the first half so that the filter has settled
+			var peak float64
+			for _, s := range samples[sampleRate/2:] {
+				peak = math.Max(peak, math.Abs(s[0]))
+			}
+			assert.True(t, math.Abs(20*math.Log10(peak)-c.gain) < 0.1)
+		})
+	}
+}
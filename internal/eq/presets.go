@@ -0,0 +1,107 @@
+package eq
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+// Flat is the name of the built-in preset, which has no bands.
+const Flat = "flat"
+
+// LoadPresets loads the presets in the file at path, or at a default location
+// in the XDG config directory if path is nil. The file doesn't have to exist.
+// The returned presets always include Flat.
+//
+// Each line of the file that isn't empty or a comment starting with '#'
+// contains the name of a preset, followed by its bands in the form
+// frequency:gain:q, separated by whitespace. For example:
+//
+//	# boost the bass and cut the low mids a little
+//	bass 60:6:0.7 250:-2:1
+func LoadPresets(path *string) (map[string]Preset, error) {
+	var p string
+	if path != nil {
+		p = *path
+	} else {
+		var err error
+		p, err = xdg.ConfigFile(filepath.Join("q", "eq.conf"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve presets path: %w", err)
+		}
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Preset{Flat: nil}, nil
+		}
+
+		return nil, fmt.Errorf("failed to open presets file: %w", err)
+	}
+	defer func() { _ = f.Close() }() // intentionally ignore close error
+
+	return parsePresets(f)
+}
+
+func parsePresets(r io.Reader) (map[string]Preset, error) {
+	presets := map[string]Preset{Flat: nil}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		name := fields[0]
+		if _, ok := presets[name]; ok {
+			return nil, fmt.Errorf(`line %d: duplicate preset "%s"`, line, name)
+		}
+
+		preset := Preset{}
+		for _, field := range fields[1:] {
+			b, err := parseBand(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			preset = append(preset, b)
+		}
+		presets[name] = preset
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read presets file: %w", err)
+	}
+
+	return presets, nil
+}
+
+// parseBand parses a band in the form frequency:gain:q.
+func parseBand(s string) (Band, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return Band{}, fmt.Errorf(`band "%s" is not of the form frequency:gain:q`, s)
+	}
+
+	var values [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return Band{}, fmt.Errorf(`band "%s" is invalid: %w`, s, err)
+		}
+		values[i] = v
+	}
+
+	b := Band{Frequency: values[0], Gain: values[1], Q: values[2]}
+	if b.Frequency <= 0 || b.Q <= 0 {
+		return Band{}, fmt.Errorf(`band "%s" must have a positive frequency and q`, s)
+	}
+	return b, nil
+}
@@ -1,11 +1,30 @@
 package protocol
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+	"slices"
+	"time"
+
+	"mtoohey.com/q/internal/util"
+)
 
 func init() {
 	gob.Register(PauseState(false))
 	gob.Register(RepeatState(0))
 	gob.Register(ShuffleState(false))
+	gob.Register(ReplayGainMode(0))
+	gob.Register(CrossfadeState(0))
+	gob.Register(VolumeState(0))
+	gob.Register(MuteState(false))
+	gob.Register(SpeedState(0))
+	gob.Register(PreservePitchState(false))
+	gob.Register(EQState(""))
+	gob.Register(StopState(false))
+	gob.Register(NightModeState(false))
+	gob.Register(BalanceState(0))
+	gob.Register(MonoState(false))
+	gob.Register(SwapChannelsState(false))
+	gob.Register(LoopState{})
 }
 
 // This file contains messages that can be sent by the server as a notification
@@ -19,6 +38,13 @@ type Message any
 // PauseState indicates whether the player is currently paused.
 type PauseState bool
 
+// StopState indicates whether the player is stopped, which means that it has
+// released its audio output. The server stops once it has been paused or had
+// an empty queue for some time, and resumes once it is unpaused and has
+// something to play. A client requesting a stop also pauses the player, and
+// a client requesting a resume also unpauses it.
+type StopState bool
+
 // RepeatState indicates the current repeat mode of the player.
 type RepeatState uint8
 
@@ -46,3 +72,216 @@ func (r RepeatState) Prev() RepeatState {
 
 // ShuffleState indicates whether the queue is being shuffled.
 type ShuffleState bool
+
+// ReplayGainMode indicates which ReplayGain values are used to normalize the
+// volume of songs.
+type ReplayGainMode uint8
+
+const (
+	// ReplayGainModeOff means that volume will not be normalized.
+	ReplayGainModeOff ReplayGainMode = iota
+
+	// ReplayGainModeTrack means that each song will be normalized on its own.
+	ReplayGainModeTrack
+
+	// ReplayGainModeAlbum means that songs will be normalized relative to the
+	// rest of their album, preserving differences in volume between songs on
+	// the same album.
+	ReplayGainModeAlbum
+
+	// ReplayGainModeAuto means that album values will be used when the queue
+	// isn't being shuffled, and track values will be used when it is.
+	ReplayGainModeAuto
+)
+
+// Next returns the ReplayGain mode following the current one.
+func (r ReplayGainMode) Next() ReplayGainMode {
+	return (r + 1) % 4
+}
+
+// Prev returns the ReplayGain mode preceding the current one.
+func (r ReplayGainMode) Prev() ReplayGainMode {
+	return (r + 3) % 4
+}
+
+// CrossfadeState is the duration over which songs are crossfaded when one
+// transitions to the next. Songs aren't crossfaded if it is 0.
+type CrossfadeState time.Duration
+
+// crossfadeSteps contains the crossfade durations that Next and Prev cycle
+// through.
+var crossfadeSteps = [...]CrossfadeState{
+	0,
+	CrossfadeState(2 * time.Second),
+	CrossfadeState(5 * time.Second),
+	CrossfadeState(10 * time.Second),
+}
+
+// Next returns the shortest crossfade duration in a fixed set of steps that is
+// longer than the current one, or 0 if there isn't one.
+func (c CrossfadeState) Next() CrossfadeState {
+	for _, step := range crossfadeSteps {
+		if step > c {
+			return step
+		}
+	}
+	return 0
+}
+
+// Prev returns the longest crossfade duration in a fixed set of steps that is
+// shorter than the current one, or the longest step if there isn't one.
+func (c CrossfadeState) Prev() CrossfadeState {
+	for i := len(crossfadeSteps) - 1; i >= 0; i-- {
+		if crossfadeSteps[i] < c {
+			return crossfadeSteps[i]
+		}
+	}
+	return crossfadeSteps[len(crossfadeSteps)-1]
+}
+
+// VolumeState is the volume of the player, as a percentage between 0 and
+// MaxVolume.
+type VolumeState uint8
+
+// MaxVolume is the maximum volume, at which samples are played unchanged.
+const MaxVolume VolumeState = 100
+
+// Add returns the volume changed by delta percentage points, clamped between 0
+// and MaxVolume.
+func (v VolumeState) Add(delta int) VolumeState {
+	return VolumeState(util.Clamp(0, int(v)+delta, int(MaxVolume)))
+}
+
+// Gain returns the factor by which samples are multiplied at this volume.
+// Volume is perceived roughly logarithmically, so the gain follows a cubic
+// curve, which approximates that while still reaching 0.
+func (v VolumeState) Gain() float64 {
+	f := float64(v) / float64(MaxVolume)
+	return f * f * f
+}
+
+// MuteState indicates whether the player is muted. The volume is preserved
+// while muted.
+type MuteState bool
+
+// SpeedState is the rate at which songs are played, relative to their original
+// speed. It is between MinSpeed and MaxSpeed.
+type SpeedState float64
+
+const (
+	// MinSpeed is the slowest supported speed.
+	MinSpeed SpeedState = 0.5
+	// MaxSpeed is the fastest supported speed.
+	MaxSpeed SpeedState = 3
+)
+
+// speedSteps contains the speeds that Next and Prev step through.
+var speedSteps = [...]SpeedState{MinSpeed, 0.75, 1, 1.25, 1.5, 1.75, 2, 2.5, MaxSpeed}
+
+// Next returns the slowest speed in a fixed set of steps that is faster than
+// the current one, or MaxSpeed if there isn't one.
+func (s SpeedState) Next() SpeedState {
+	for _, step := range speedSteps {
+		if step > s {
+			return step
+		}
+	}
+	return MaxSpeed
+}
+
+// Prev returns the fastest speed in a fixed set of steps that is slower than
+// the current one, or MinSpeed if there isn't one.
+func (s SpeedState) Prev() SpeedState {
+	for i := len(speedSteps) - 1; i >= 0; i-- {
+		if speedSteps[i] < s {
+			return speedSteps[i]
+		}
+	}
+	return MinSpeed
+}
+
+// PreservePitchState indicates whether songs are time-stretched so that their
+// pitch is preserved when they are played at a different speed, instead of
+// being resampled.
+type PreservePitchState bool
+
+// EQState is the name of the active equalizer preset. The available presets
+// are listed in State.
+type EQState string
+
+// Next returns the preset following the current one in presets, wrapping
+// around to the first one.
+func (e EQState) Next(presets []EQState) EQState {
+	return e.offset(presets, 1)
+}
+
+// Prev returns the preset preceding the current one in presets, wrapping
+// around to the last one.
+func (e EQState) Prev(presets []EQState) EQState {
+	return e.offset(presets, -1)
+}
+
+func (e EQState) offset(presets []EQState, by int) EQState {
+	if len(presets) == 0 {
+		return e
+	}
+
+	// if the current preset isn't found, i is -1, so the first preset
+	// follows it
+	i := slices.Index(presets, e)
+	if i < 0 && by < 0 {
+		i = 0
+	}
+	return presets[(i+by+len(presets))%len(presets)]
+}
+
+// NightModeState indicates whether night mode is enabled, which compresses the
+// dynamic range of songs so that quiet passages are easier to hear and loud
+// ones are less disruptive.
+type NightModeState bool
+
+// BalanceState is the balance between the left and right channels, as a
+// percentage between -MaxBalance and MaxBalance. Negative values attenuate the
+// right channel, and positive values attenuate the left channel.
+type BalanceState int8
+
+// MaxBalance is the balance at which the left channel is silent.
+const MaxBalance BalanceState = 100
+
+// Add returns the balance changed by delta percentage points, clamped between
+// -MaxBalance and MaxBalance.
+func (b BalanceState) Add(delta int) BalanceState {
+	return BalanceState(util.Clamp(-int(MaxBalance), int(b)+delta, int(MaxBalance)))
+}
+
+// Gains returns the factors by which the left and right channels are
+// multiplied at this balance.
+func (b BalanceState) Gains() (left, right float64) {
+	f := float64(b) / float64(MaxBalance)
+	return util.Min(1-f, 1), util.Min(1+f, 1)
+}
+
+// MonoState indicates whether the channels are mixed down to mono, so that
+// both play the same thing.
+type MonoState bool
+
+// SwapChannelsState indicates whether the left and right channels are
+// swapped.
+type SwapChannelsState bool
+
+// LoopState is a section of the current song that is played repeatedly. While
+// a loop is set, it takes precedence over the repeat state, so the current
+// song doesn't end. The loop is cleared when a different song starts playing.
+// The zero value means that there is no loop.
+type LoopState struct {
+	// A is the position in the song where the loop starts.
+	A time.Duration
+	// B is the position in the song where the loop ends, and playback
+	// returns to A.
+	B time.Duration
+}
+
+// Active returns whether l is a loop rather than the zero value.
+func (l LoopState) Active() bool {
+	return l.B > l.A
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/gob"
 	"image"
+	"sort"
 	"time"
 )
 
@@ -26,6 +27,7 @@ func init() {
 	gob.Register(NowPlayingState{})
 	gob.Register(ProgressState{})
 	gob.Register(QueueState{})
+	gob.Register(LyricsState{})
 	gob.Register(Removed(""))
 }
 
@@ -45,18 +47,64 @@ type State struct {
 	// Pause is the current pause state.
 	Pause PauseState
 
+	// Stop is the current stop state.
+	Stop StopState
+
 	// Progress is the current progress state.
 	Progress ProgressState
 
 	// Repeat is the current repeat state.
 	Repeat RepeatState
 
+	// Loop is the current loop state.
+	Loop LoopState
+
 	// Shuffle is the current shuffle state.
 	Shuffle ShuffleState
 
+	// ReplayGain is the current ReplayGain mode.
+	ReplayGain ReplayGainMode
+
+	// Crossfade is the current crossfade duration.
+	Crossfade CrossfadeState
+
+	// Volume is the current volume.
+	Volume VolumeState
+
+	// Mute is the current mute state.
+	Mute MuteState
+
+	// Speed is the current speed.
+	Speed SpeedState
+
+	// PreservePitch is the current preserve pitch state.
+	PreservePitch PreservePitchState
+
+	// Balance is the current balance.
+	Balance BalanceState
+
+	// Mono is the current mono state.
+	Mono MonoState
+
+	// SwapChannels is the current swap channels state.
+	SwapChannels SwapChannelsState
+
+	// NightMode is the current night mode state.
+	NightMode NightModeState
+
+	// EQ is the current equalizer preset.
+	EQ EQState
+
+	// EQPresets contains the names of the available equalizer presets, in
+	// alphabetical order.
+	EQPresets []EQState
+
 	// Queue is the current queue state.
 	Queue QueueState
 
+	// Lyrics is the current lyrics state.
+	Lyrics LyricsState
+
 	// Version is the version of the server. This should be checked to ensure
 	// compatibility with the client.
 	Version string
@@ -79,7 +127,8 @@ type NowPlayingState struct {
 }
 
 // ProgressState contains information about the player's progress through the
-// current song.
+// current song. Both durations are measured in the time of the song, so they
+// aren't affected by the speed.
 type ProgressState struct {
 	// Current is the player's position within the current song.
 	Current time.Duration
@@ -87,6 +136,40 @@ type ProgressState struct {
 	Total time.Duration
 }
 
+// LyricsState contains the lyrics of the current song. Lines is empty if the
+// song has no lyrics.
+type LyricsState struct {
+	// Synced indicates whether the lines have offsets. If it is false, the
+	// offsets of all lines are zero.
+	Synced bool
+
+	// Lines contains the lines of the lyrics, sorted by offset if they are
+	// synced.
+	Lines []LyricsLine
+}
+
+// LyricsLine is a single line of lyrics.
+type LyricsLine struct {
+	// Offset is the position in the song at which this line begins.
+	Offset time.Duration
+
+	// Text is the text of the line.
+	Text string
+}
+
+// Line returns the index of the line that is active at the given position in
+// the song, or -1 if the lyrics aren't synced or the first line hasn't begun
+// yet.
+func (l LyricsState) Line(position time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+
+	return sort.Search(len(l.Lines), func(i int) bool {
+		return l.Lines[i].Offset > position
+	}) - 1
+}
+
 // QueueState contains information about the current queue.
 type QueueState []QueueItem
 
@@ -0,0 +1,73 @@
+package remote
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// negativeNumber matches arguments that start like negative numbers or
+// durations. No short flags are digits or periods, so these are never flags.
+var negativeNumber = regexp.MustCompile(`^-[0-9.]`)
+
+// negativeArgCommands contains the remote commands whose positional argument
+// may be negative.
+var negativeArgCommands = map[string]struct{}{
+	"volume":  {},
+	"balance": {},
+	"seek":    {},
+}
+
+// EscapeNegativeNumbers moves the positional argument of the remote commands
+// that accept negative numbers, such as the -5 in "remote volume -5", after a
+// "--" so that kong parses it as a positional argument instead of rejecting it
+// as an unknown short flag. Other commands and arguments are left alone.
+func EscapeNegativeNumbers(args []string) []string {
+	r := nextPositional(args, 0)
+	if r == len(args) || args[r] != "remote" && args[r] != "r" {
+		return args
+	}
+
+	c := nextPositional(args, r+1)
+	if c == len(args) {
+		return args
+	}
+	if _, ok := negativeArgCommands[args[c]]; !ok {
+		return args
+	}
+
+	// these commands take a single positional argument, and none of their
+	// flags take negative values
+	for i := c + 1; i < len(args); i++ {
+		if args[i] == "--" {
+			return args
+		}
+
+		if negativeNumber.MatchString(args[i]) {
+			escaped := append(slices.Clone(args[:i]), args[i+1:]...)
+			return append(escaped, "--", args[i])
+		}
+	}
+
+	return args
+}
+
+// nextPositional returns the index of the first argument from i onwards that
+// isn't a flag or the value of one, or len(args) if there isn't one. Flags are
+// assumed to take values, as the global flags do.
+func nextPositional(args []string, i int) int {
+	for ; i < len(args); i++ {
+		if args[i] == "--" {
+			return len(args)
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			return i
+		}
+		if !strings.Contains(args[i], "=") {
+			// skip the value
+			i++
+		}
+	}
+
+	return len(args)
+}
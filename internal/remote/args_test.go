@@ -0,0 +1,77 @@
+package remote
+
+import (
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestEscapeNegativeNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "positive",
+			args:     []string{"remote", "volume", "+5"},
+			expected: []string{"remote", "volume", "+5"},
+		},
+		{
+			name:     "volume",
+			args:     []string{"remote", "volume", "-5"},
+			expected: []string{"remote", "volume", "--", "-5"},
+		},
+		{
+			name:     "balance alias",
+			args:     []string{"r", "balance", "-100"},
+			expected: []string{"r", "balance", "--", "-100"},
+		},
+		{
+			name:     "seek",
+			args:     []string{"remote", "seek", "-1m30s"},
+			expected: []string{"remote", "seek", "--", "-1m30s"},
+		},
+		{
+			name:     "global flags before",
+			args:     []string{"-u", "sock", "--sample-rate=48000", "remote", "volume", "-5"},
+			expected: []string{"-u", "sock", "--sample-rate=48000", "remote", "volume", "--", "-5"},
+		},
+		{
+			name:     "flags after",
+			args:     []string{"remote", "balance", "-20", "-u", "sock"},
+			expected: []string{"remote", "balance", "-u", "sock", "--", "-20"},
+		},
+		{
+			name:     "after boolean flag",
+			args:     []string{"remote", "volume", "-c", "-5"},
+			expected: []string{"remote", "volume", "-c", "--", "-5"},
+		},
+		{
+			name:     "already escaped",
+			args:     []string{"remote", "volume", "--", "-5"},
+			expected: []string{"remote", "volume", "--", "-5"},
+		},
+		{
+			name:     "other remote command",
+			args:     []string{"remote", "insert", "-1", "path"},
+			expected: []string{"remote", "insert", "-1", "path"},
+		},
+		{
+			name:     "other command",
+			args:     []string{"server", "a", "-1b", "c"},
+			expected: []string{"server", "a", "-1b", "c"},
+		},
+		{
+			name:     "command as a song",
+			args:     []string{"server", "remote", "volume", "-5", "c"},
+			expected: []string{"server", "remote", "volume", "-5", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, EscapeNegativeNumbers(tt.args))
+		})
+	}
+}
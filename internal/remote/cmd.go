@@ -3,8 +3,11 @@ package remote
 import (
 	_ "embed"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
@@ -38,6 +41,10 @@ type Cmd struct {
 		PauseState *protocol.PauseState `arg:"" optional:"true" type:"boolarg" help:"New pause state."`
 		Cycle      bool                 `short:"c" help:"Cycle current pause state."`
 	} `cmd:"" help:"Pause playback."`
+	Stop struct {
+		StopState *protocol.StopState `arg:"" optional:"true" type:"boolarg" help:"New stop state."`
+		Cycle     bool                `short:"c" help:"Cycle current stop state."`
+	} `cmd:"" help:"Stop playback and release the audio output."`
 	Repeat struct {
 		RepeatState *protocol.RepeatState `arg:"" optional:"true" help:"New repeat state."`
 		Cycle       bool                  `short:"c" help:"Cycle current repeat state."`
@@ -46,12 +53,57 @@ type Cmd struct {
 		ShuffleState *protocol.ShuffleState `arg:"" optional:"true" type:"boolarg" help:"New shuffle state."`
 		Cycle        bool                   `short:"c" help:"Cycle current shuffle state."`
 	} `cmd:"" help:"Set shuffle state."`
+	ReplayGain struct {
+		ReplayGainMode *protocol.ReplayGainMode `arg:"" optional:"true" help:"New ReplayGain mode."`
+		Cycle          bool                     `short:"c" help:"Cycle current ReplayGain mode."`
+	} `cmd:"" help:"Set ReplayGain mode."`
+	Crossfade struct {
+		Duration protocol.CrossfadeState `arg:"" help:"New crossfade duration, or 0 to disable crossfading."`
+	} `cmd:"" help:"Set crossfade duration."`
+	Volume struct {
+		By *string `arg:"" optional:"true" help:"Change the volume relative to the current volume when prefixed with + or -, such as +5 or -5, or absolutely if no prefix is given. The current volume is displayed if this is omitted."`
+	} `cmd:"" help:"Set or display volume."`
+	Mute struct {
+		MuteState *protocol.MuteState `arg:"" optional:"true" type:"boolarg" help:"New mute state."`
+		Cycle     bool                `short:"c" help:"Cycle current mute state."`
+	} `cmd:"" help:"Mute playback."`
+	Speed struct {
+		Speed *protocol.SpeedState `arg:"" optional:"true" help:"New speed."`
+		Cycle bool                 `short:"c" help:"Step up to the next speed, wrapping around to the slowest."`
+	} `cmd:"" help:"Set speed."`
+	PreservePitch struct {
+		PreservePitchState *protocol.PreservePitchState `arg:"" optional:"true" type:"boolarg" help:"New preserve pitch state."`
+		Cycle              bool                         `short:"c" help:"Cycle current preserve pitch state."`
+	} `cmd:"" help:"Set whether pitch is preserved when changing speed."`
+	Balance struct {
+		Balance *protocol.BalanceState `arg:"" optional:"true" help:"New balance, as a percentage. Negative values, such as -20, attenuate the right channel, and positive values attenuate the left channel. Defaults to 0."`
+	} `cmd:"" help:"Set balance between the left and right channels."`
+	Mono struct {
+		MonoState *protocol.MonoState `arg:"" optional:"true" type:"boolarg" help:"New mono state."`
+		Cycle     bool                `short:"c" help:"Cycle current mono state."`
+	} `cmd:"" help:"Mix the channels down to mono."`
+	SwapChannels struct {
+		SwapChannelsState *protocol.SwapChannelsState `arg:"" optional:"true" type:"boolarg" help:"New swap channels state."`
+		Cycle             bool                        `short:"c" help:"Cycle current swap channels state."`
+	} `cmd:"" help:"Swap the left and right channels."`
+	NightMode struct {
+		NightModeState *protocol.NightModeState `arg:"" optional:"true" type:"boolarg" help:"New night mode state."`
+		Cycle          bool                     `short:"c" help:"Cycle current night mode state."`
+	} `cmd:"" help:"Set night mode, which compresses the dynamic range of songs."`
+	EQ struct {
+		Preset *protocol.EQState `arg:"" optional:"true" help:"New equalizer preset. The current preset is displayed if this is omitted."`
+		Cycle  bool              `short:"c" help:"Cycle current equalizer preset."`
+	} `cmd:"" help:"Set or display equalizer preset."`
 	Skip struct {
 		Songs protocol.Skip `arg:"" default:"1" help:"Number of songs to skip."`
 	} `cmd:"" help:"Skip song(s)."`
 	Seek struct {
 		By string `arg:"" help:"Seek either +/- the current time, or absolutely if no prefix is given."`
 	} `cmd:"" help:"Seek within the current song."`
+	Loop struct {
+		A *string `arg:"" optional:"true" help:"Position where the loop starts, either as [[h:]m:]s or a duration such as 1m2s. The loop is cleared if this is omitted."`
+		B *string `arg:"" optional:"true" help:"Position where the loop ends, in the same form as the start. Defaults to the end of the song."`
+	} `cmd:"" help:"Repeat a section of the current song."`
 	Remove struct {
 		Index int `arg:"" help:"Song index to remove from queue."`
 	} `cmd:"" help:"Remove a song from the queue."`
@@ -120,6 +172,15 @@ func (c *Cmd) Run(ctx *kong.Context, g cmd.Globals) (err error) {
 			m = protocol.PauseState(true)
 		}
 
+	case "remote stop", "remote stop <stop-state>":
+		if c.Stop.StopState != nil {
+			m = c.Stop.StopState
+		} else if c.Stop.Cycle {
+			m = !state.Stop
+		} else {
+			m = protocol.StopState(true)
+		}
+
 	case "remote repeat", "remote repeat <repeat-state>":
 		if c.Repeat.RepeatState != nil {
 			m = c.Repeat.RepeatState
@@ -138,6 +199,111 @@ func (c *Cmd) Run(ctx *kong.Context, g cmd.Globals) (err error) {
 			m = protocol.ShuffleState(true)
 		}
 
+	case "remote replay-gain", "remote replay-gain <replay-gain-mode>":
+		if c.ReplayGain.ReplayGainMode != nil {
+			m = c.ReplayGain.ReplayGainMode
+		} else if c.ReplayGain.Cycle {
+			m = state.ReplayGain.Next()
+		} else {
+			m = protocol.ReplayGainModeAuto
+		}
+
+	case "remote crossfade <duration>":
+		m = c.Crossfade.Duration
+
+	case "remote volume":
+		fmt.Println(state.Volume)
+		return nil
+
+	case "remote volume <by>":
+		n, err := strconv.Atoi(*c.Volume.By)
+		if err != nil {
+			return fmt.Errorf(`invalid volume "%s": %w`, *c.Volume.By, err)
+		}
+
+		// must be at least length 1 because "" is an invalid integer
+		switch (*c.Volume.By)[0] {
+		case '+', '-':
+			m = state.Volume.Add(n)
+		default:
+			m = protocol.VolumeState(0).Add(n)
+		}
+
+	case "remote mute", "remote mute <mute-state>":
+		if c.Mute.MuteState != nil {
+			m = c.Mute.MuteState
+		} else if c.Mute.Cycle {
+			m = !state.Mute
+		} else {
+			m = protocol.MuteState(true)
+		}
+
+	case "remote speed", "remote speed <speed>":
+		if c.Speed.Speed != nil {
+			m = c.Speed.Speed
+		} else if c.Speed.Cycle {
+			if state.Speed == protocol.MaxSpeed {
+				m = protocol.MinSpeed
+			} else {
+				m = state.Speed.Next()
+			}
+		} else {
+			m = protocol.SpeedState(1)
+		}
+
+	case "remote preserve-pitch", "remote preserve-pitch <preserve-pitch-state>":
+		if c.PreservePitch.PreservePitchState != nil {
+			m = c.PreservePitch.PreservePitchState
+		} else if c.PreservePitch.Cycle {
+			m = !state.PreservePitch
+		} else {
+			m = protocol.PreservePitchState(true)
+		}
+
+	case "remote balance", "remote balance <balance>":
+		if c.Balance.Balance != nil {
+			m = c.Balance.Balance
+		} else {
+			m = protocol.BalanceState(0)
+		}
+
+	case "remote mono", "remote mono <mono-state>":
+		if c.Mono.MonoState != nil {
+			m = c.Mono.MonoState
+		} else if c.Mono.Cycle {
+			m = !state.Mono
+		} else {
+			m = protocol.MonoState(true)
+		}
+
+	case "remote swap-channels", "remote swap-channels <swap-channels-state>":
+		if c.SwapChannels.SwapChannelsState != nil {
+			m = c.SwapChannels.SwapChannelsState
+		} else if c.SwapChannels.Cycle {
+			m = !state.SwapChannels
+		} else {
+			m = protocol.SwapChannelsState(true)
+		}
+
+	case "remote night-mode", "remote night-mode <night-mode-state>":
+		if c.NightMode.NightModeState != nil {
+			m = c.NightMode.NightModeState
+		} else if c.NightMode.Cycle {
+			m = !state.NightMode
+		} else {
+			m = protocol.NightModeState(true)
+		}
+
+	case "remote eq", "remote eq <preset>":
+		if c.EQ.Preset != nil {
+			m = c.EQ.Preset
+		} else if c.EQ.Cycle {
+			m = state.EQ.Next(state.EQPresets)
+		} else {
+			fmt.Println(state.EQ)
+			return nil
+		}
+
 	case "remote skip", "remote skip <songs>":
 		m = c.Skip.Songs
 
@@ -154,6 +320,27 @@ func (c *Cmd) Run(ctx *kong.Context, g cmd.Globals) (err error) {
 		}
 		m = protocol.Seek(d)
 
+	case "remote loop":
+		m = protocol.LoopState{}
+
+	case "remote loop <a>", "remote loop <a> <b>":
+		a, err := parsePosition(*c.Loop.A)
+		if err != nil {
+			return err
+		}
+
+		b := state.Progress.Total
+		if c.Loop.B != nil {
+			if b, err = parsePosition(*c.Loop.B); err != nil {
+				return err
+			}
+		}
+
+		if a >= b {
+			return fmt.Errorf("loop start %s must be before loop end %s", a, b)
+		}
+		m = protocol.LoopState{A: a, B: b}
+
 	case "remote remove <index>":
 		m = protocol.Remove(c.Remove.Index)
 
@@ -187,3 +374,27 @@ func (c *Cmd) Run(ctx *kong.Context, g cmd.Globals) (err error) {
 
 	return nil
 }
+
+// parsePosition parses a position within a song, either as a duration, or in
+// the form [[h:]m:]s, where the seconds may be fractional.
+func parsePosition(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf(`invalid position "%s": too many components`, s)
+	}
+
+	var d time.Duration
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		// only the seconds may be fractional
+		if err != nil || v < 0 || i < len(parts)-1 && v != math.Trunc(v) {
+			return 0, fmt.Errorf(`invalid position "%s"`, s)
+		}
+		d = d*60 + time.Duration(v*float64(time.Second))
+	}
+	return d, nil
+}
@@ -0,0 +1,36 @@
+package remote
+
+import (
+	"testing"
+	"time"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{input: "90", expected: 90 * time.Second},
+		{input: "1:02", expected: 62 * time.Second},
+		{input: "1m2s", expected: 62 * time.Second},
+		{input: "1:02:03", expected: time.Hour + 2*time.Minute + 3*time.Second},
+		{input: "1:02.5", expected: 62*time.Second + 500*time.Millisecond},
+		{input: "", err: true},
+		{input: "abc", err: true},
+		{input: "-1", err: true},
+		{input: "1.5:00", err: true},
+		{input: "1:-2", err: true},
+		{input: "1:2:3:4", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := parsePosition(tt.input)
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+
+	"mtoohey.com/q/internal/track"
+)
+
+// analyzeUpcomingLocked analyzes the current and next songs in the background
+// if their loudness or silence is needed but hasn't been analyzed yet. If the
+// analysis of the current song finishes while it's still playing, its gain and
+// trimming are updated right away. queue should be locked before this method
+// is called.
+func (s *Server) analyzeUpcomingLocked() {
+	if s.analyses == nil {
+		return
+	}
+
+	var upcoming []*track.Track
+	if head, ok := s.queue.Head(); ok {
+		upcoming = append(upcoming, head)
+	}
+	if next, ok := s.queue.Next(); ok {
+		upcoming = append(upcoming, next)
+	}
+
+	go func() {
+		for i, t := range upcoming {
+			if !s.needsAnalysis(t) {
+				continue
+			}
+
+			if _, err := s.analyses.Analyze(t, s.silenceThreshold); err != nil {
+				s.broadcastErr(fmt.Errorf("failed to analyze song: %w", err))
+				continue
+			}
+			if err := s.analyses.Save(); err != nil {
+				s.broadcastErr(fmt.Errorf("failed to save analysis: %w", err))
+			}
+
+			if i == 0 {
+				s.updateAnalysisOf(t)
+			}
+		}
+	}()
+}
+
+// needsAnalysis returns whether t's silence is needed but hasn't been detected
+// yet, or whether t's loudness is needed because it doesn't have ReplayGain
+// tags but hasn't been analyzed yet.
+func (s *Server) needsAnalysis(t *track.Track) bool {
+	r, ok := s.analyses.Get(t.Path)
+	if s.trimSilence && !(ok && r.HasSilence(s.silenceThreshold)) {
+		return true
+	}
+	if !s.analyzeLoudness || ok {
+		return false
+	}
+
+	rg, err := t.ReplayGain()
+	return err == nil && rg == nil
+}
+
+// updateAnalysisOf updates the gain and trimming applied to the current
+// streamer if t is the current song.
+func (s *Server) updateAnalysisOf(t *track.Track) {
+	s.sink.Lock()
+	s.queueMu.Lock()
+	s.streamerMu.Lock()
+	if head, ok := s.queue.Head(); ok && head == t {
+		s.updateGainLocked()
+		s.updateTrimLocked()
+	}
+	s.streamerMu.Unlock()
+	s.queueMu.Unlock()
+	s.sink.Unlock()
+}
@@ -83,7 +83,7 @@ func (s *Server) getNowPlayingLocked() protocol.NowPlayingState {
 		s.broadcastErr(fmt.Errorf("failed to get queue[0] artist: %w", err))
 	}
 
-	cover, err := head.Cover()
+	cover, err := head.Cover(s.coverNames)
 	if err != nil {
 		s.broadcastErr(fmt.Errorf("failed to get queue[0] cover: %w", err))
 	}
@@ -95,11 +95,41 @@ func (s *Server) getNowPlayingLocked() protocol.NowPlayingState {
 	}
 }
 
+// getLyricsLocked returns the lyrics of the current song. If an error is
+// encountered, it will be broadcasted, and empty lyrics will be returned.
+// queue should be locked.
+func (s *Server) getLyricsLocked() protocol.LyricsState {
+	head, ok := s.queue.Head()
+	if !ok {
+		return protocol.LyricsState{}
+	}
+
+	lyrics, err := head.Lyrics()
+	if err != nil {
+		s.broadcastErr(fmt.Errorf("failed to get queue[0] lyrics: %w", err))
+		return protocol.LyricsState{}
+	}
+	if lyrics == nil {
+		return protocol.LyricsState{}
+	}
+
+	lines := make([]protocol.LyricsLine, len(lyrics.Lines))
+	for i, line := range lyrics.Lines {
+		lines[i] = protocol.LyricsLine{Offset: line.Offset, Text: line.Text}
+	}
+
+	return protocol.LyricsState{
+		Synced: lyrics.Synced,
+		Lines:  lines,
+	}
+}
+
 // broadcastNowPlayingLocked sends the now playing state to all clients.
 // streamer and queue should be locked.
 func (s *Server) broadcastNowPlayingLocked() {
 	s.broadcast(s.getProgressLocked())
 	s.broadcast(s.getNowPlayingLocked())
+	s.broadcast(s.getLyricsLocked())
 }
 
 // getProgress retrieves the current progress.
@@ -147,20 +177,55 @@ func (s *Server) getState() protocol.State {
 	paused := s.paused
 	s.pausedMu.RUnlock()
 
+	s.stoppedMu.Lock()
+	stopped := s.stopped
+	s.stoppedMu.Unlock()
+
+	s.streamerMu.RLock()
+	replayGain := s.replayGainMode
+	crossfade := s.crossfadeDuration
+	volume := s.volume
+	mute := s.mute
+	speed := s.speed
+	preservePitch := s.preservePitch
+	balance := s.balance
+	mono := s.mono
+	swapChannels := s.swapChannels
+	nightMode := s.nightMode
+	eqPreset := s.eqPreset
+	loop := s.loop
+	s.streamerMu.RUnlock()
+
 	s.queueMu.RLock()
 	nowPlaying := s.getNowPlayingLocked()
 	repeat := s.queue.Repeat
 	shuffle := s.queue.Shuffle
 	queue := s.getQueueLocked()
+	lyrics := s.getLyricsLocked()
 	s.queueMu.RUnlock()
 
 	return protocol.State{
-		NowPlaying: nowPlaying,
-		Pause:      paused,
-		Progress:   s.getProgress(),
-		Repeat:     repeat,
-		Shuffle:    shuffle,
-		Queue:      queue,
-		Version:    protocol.Version,
+		NowPlaying:    nowPlaying,
+		Pause:         paused,
+		Stop:          stopped,
+		Progress:      s.getProgress(),
+		Repeat:        repeat,
+		Loop:          loop,
+		Shuffle:       shuffle,
+		ReplayGain:    replayGain,
+		Crossfade:     crossfade,
+		Volume:        volume,
+		Mute:          mute,
+		Speed:         speed,
+		PreservePitch: preservePitch,
+		Balance:       balance,
+		Mono:          mono,
+		SwapChannels:  swapChannels,
+		NightMode:     nightMode,
+		EQ:            eqPreset,
+		EQPresets:     s.getEQPresets(),
+		Queue:         queue,
+		Lyrics:        lyrics,
+		Version:       protocol.Version,
 	}
 }
@@ -0,0 +1,21 @@
+package server
+
+// applyChannelsLocked swaps, mixes down, and balances the channels of samples
+// according to the current settings. streamerMu should be locked.
+func (s *Server) applyChannelsLocked(samples [][2]float64) {
+	if s.balance == 0 && !bool(s.mono) && !bool(s.swapChannels) {
+		return
+	}
+
+	left, right := s.balance.Gains()
+	for i, sample := range samples {
+		if s.swapChannels {
+			sample[0], sample[1] = sample[1], sample[0]
+		}
+		if s.mono {
+			m := (sample[0] + sample[1]) / 2
+			sample = [2]float64{m, m}
+		}
+		samples[i] = [2]float64{sample[0] * left, sample[1] * right}
+	}
+}
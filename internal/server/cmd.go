@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"time"
 
 	"mtoohey.com/q/internal/cmd"
 	"mtoohey.com/q/internal/protocol"
@@ -16,6 +17,73 @@ type Cmd struct {
 	Shuffle protocol.ShuffleState `short:"s" negatable:"true" default:"true" help:"Initial shuffle state."`
 	// Repeat is the initial repeat state.
 	Repeat protocol.RepeatState `short:"r" default:"queue" help:"Initial repeat state."`
+	// ReplayGain is the initial ReplayGain mode.
+	ReplayGain protocol.ReplayGainMode `default:"auto" help:"Initial ReplayGain mode."`
+	// Preamp is the gain in dB that is applied in addition to ReplayGain
+	// values.
+	Preamp float64 `default:"0" help:"Gain in dB to apply in addition to ReplayGain values."`
+	// Crossfade is the initial crossfade duration.
+	Crossfade protocol.CrossfadeState `default:"0s" help:"Initial crossfade duration. Songs aren't crossfaded if it is 0."`
+	// CrossfadeManual indicates whether manual skips and jumps should be
+	// crossfaded too.
+	CrossfadeManual bool `help:"Also crossfade when skipping or jumping manually."`
+	// Volume is the initial volume.
+	Volume protocol.VolumeState `default:"100" help:"Initial volume, as a percentage."`
+	// Mute is the initial mute state.
+	Mute protocol.MuteState `help:"Start muted."`
+	// Speed is the initial speed.
+	Speed protocol.SpeedState `default:"1" help:"Initial speed, relative to the original speed of songs."`
+	// PreservePitch is the initial preserve pitch state.
+	PreservePitch protocol.PreservePitchState `help:"Preserve the pitch of songs when changing their speed."`
+	// EQ is the initial equalizer preset.
+	EQ protocol.EQState `default:"flat" help:"Initial equalizer preset."`
+	// EQPresets is the path of the file containing equalizer presets. A file
+	// in the XDG config directory is used if this flag is not provided.
+	EQPresets *string `help:"The path of the file containing equalizer presets. A file in the XDG config directory is used if this flag is not provided."`
+	// Sink is the output that audio is played to.
+	Sink string `enum:"speaker,null,wav,pcm" default:"speaker" help:"Output to play audio to: the default audio device, nowhere, a WAV file, or raw signed 16-bit little-endian PCM."`
+	// SinkPath is the path of the file that the wav and pcm sinks write to.
+	// The pcm sink writes to stdout if it is not provided.
+	SinkPath *string `help:"The path of the file that the wav and pcm sinks write to, which may be a FIFO for the pcm sink. The pcm sink writes to stdout if this flag is not provided."`
+	// SinkRealtime indicates whether sinks other than the speaker should
+	// consume audio in real time, rather than as fast as possible.
+	SinkRealtime bool `negatable:"true" default:"true" help:"Consume audio in real time with sinks other than the speaker, rather than as fast as possible."`
+	// IdleTimeout is how long the server has to be paused or have an empty
+	// queue before it stops and releases its output.
+	IdleTimeout time.Duration `default:"30s" help:"How long to be paused or have an empty queue before releasing the output. The output is never released if this is 0."`
+	// Balance is the initial balance.
+	Balance protocol.BalanceState `default:"0" help:"Initial balance, as a percentage. Negative values attenuate the right channel, and positive values attenuate the left channel."`
+	// Mono is the initial mono state.
+	Mono protocol.MonoState `help:"Start with the channels mixed down to mono."`
+	// SwapChannels is the initial swap channels state.
+	SwapChannels protocol.SwapChannelsState `help:"Start with the left and right channels swapped."`
+	// NightMode is the initial night mode state.
+	NightMode protocol.NightModeState `help:"Start with night mode enabled."`
+	// NightModeThreshold is the level above which night mode compresses.
+	NightModeThreshold float64 `default:"-30" help:"Level in dBFS above which night mode compresses."`
+	// NightModeRatio is the compression ratio of night mode.
+	NightModeRatio float64 `default:"4" help:"Factor by which night mode reduces the amount by which the level exceeds the threshold."`
+	// NightModeAttack is the attack time of night mode.
+	NightModeAttack time.Duration `default:"10ms" help:"Time for night mode to react to the level exceeding the threshold."`
+	// NightModeRelease is the release time of night mode.
+	NightModeRelease time.Duration `default:"200ms" help:"Time for night mode to recover once the level falls below the threshold."`
+	// Analyze indicates whether the loudness of songs without ReplayGain tags
+	// should be analyzed so that they can be normalized too.
+	Analyze bool `negatable:"true" default:"true" help:"Analyze the loudness of songs without ReplayGain tags so that they can be normalized too."`
+	// TrimSilence indicates whether the leading and trailing silence of songs
+	// should be skipped.
+	TrimSilence bool `help:"Skip the leading and trailing silence of songs once it has been detected."`
+	// SilenceThreshold is the level below which leading and trailing samples
+	// are considered silent.
+	SilenceThreshold float64 `default:"-60" help:"Level in dBFS below which leading and trailing samples are considered silent."`
+	// Gap is the silence that is inserted between songs that play one after
+	// another.
+	Gap time.Duration `default:"0s" help:"Silence to insert between songs that play one after another."`
+	// Fade is the duration of the fades when songs are interrupted.
+	Fade time.Duration `default:"20ms" help:"Duration of the fades when pausing, resuming, seeking, or skipping, which prevent clicks. Songs aren't faded if it is 0."`
+	// CoverNames are the names of image files that are used as the cover of
+	// tracks in the same directory without an embedded one.
+	CoverNames []string `default:"${default_cover_names}" help:"Names of image files to use as the cover of tracks in the same directory without an embedded one, in order of priority. Extensions may be omitted."`
 
 	// InitialQueries are queries whose results will become the initial queue.
 	InitialQueries []string `arg:"" optional:"true" help:"Queries whose results will become the initial queue."`
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"math"
+	"time"
+
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/util"
+
+	"github.com/faiface/beep"
+)
+
+// crossfade is a song that is fading out while the next one fades in.
+type crossfade struct {
+	// streamer is the streamer of the song that is fading out.
+	streamer beep.StreamSeekCloser
+	// pos is the number of samples of the crossfade that have been streamed
+	// so far, and len is its total length in samples.
+	pos, len int
+	// buf is reused to store the samples of the song that is fading out.
+	buf [][2]float64
+}
+
+// maybeCrossfadeLocked starts crossfading into the next song if the current
+// song is about to end and it is about to be followed by another one. sink
+// and streamer should be locked before this method is called.
+func (s *Server) maybeCrossfadeLocked() {
+	// the current song doesn't end while it's looping
+	if s.crossfadeDuration == 0 || s.streamer == nil || s.crossfade != nil || s.loop.Active() {
+		return
+	}
+
+	d := time.Duration(s.crossfadeDuration)
+	// songs that are shorter than the crossfade would start fading out as soon
+	// as they start, so they aren't crossfaded
+	if s.format.SampleRate.D(s.streamer.Len()) <= d {
+		return
+	}
+
+	remaining := s.format.SampleRate.D(s.streamer.Len() - s.streamer.Position())
+	if remaining > d {
+		return
+	}
+
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if s.queue.Repeat == protocol.RepeatStateTrack {
+		return
+	}
+	if _, ok := s.queue.Next(); !ok {
+		return
+	}
+
+	s.startCrossfadeLocked(remaining)
+	s.skipLocked(1)
+}
+
+// startCrossfadeLocked starts fading out the current streamer over d, so that
+// whatever plays next fades in. Any crossfade already in progress is stopped.
+// sink and streamer should be locked before this method is called.
+func (s *Server) startCrossfadeLocked(d time.Duration) {
+	s.stopCrossfadeLocked()
+
+	if s.streamer == nil {
+		return
+	}
+
+	s.crossfade = &crossfade{streamer: s.streamer, len: util.Max(s.SampleRate.N(d), 1)}
+	// prevent playQueueTopLocked from closing the streamer
+	s.streamer, s.gain = nil, nil
+}
+
+// stopCrossfadeLocked immediately stops the crossfade in progress, if there is
+// one. sink and streamer should be locked before this method is called.
+func (s *Server) stopCrossfadeLocked() {
+	if s.crossfade == nil {
+		return
+	}
+
+	_ = s.crossfade.streamer.Close() // intentionally ignore close error
+	s.crossfade = nil
+}
+
+// manualCrossfadeLocked prepares for a manual transition to another song,
+// starting a crossfade if manual crossfades are enabled, or fading out and
+// stopping any crossfade in progress otherwise. sink, queue, and streamer
+// should be locked before this method is called.
+func (s *Server) manualCrossfadeLocked() {
+	s.pausedMu.RLock()
+	paused := s.paused
+	s.pausedMu.RUnlock()
+
+	if s.crossfadeManual && s.crossfadeDuration > 0 && !bool(paused) &&
+		s.queue.Repeat != protocol.RepeatStateTrack {
+
+		s.startCrossfadeLocked(time.Duration(s.crossfadeDuration))
+	} else {
+		s.fadeOutLocked()
+		s.stopCrossfadeLocked()
+	}
+}
+
+// mixCrossfadeLocked mixes the song that is fading out into samples, which
+// should contain the samples of the song that is fading in, using an
+// equal-power curve. sink and streamer should be locked before this method
+// is called.
+func (s *Server) mixCrossfadeLocked(samples [][2]float64) {
+	c := s.crossfade
+	if c == nil {
+		return
+	}
+
+	if cap(c.buf) < len(samples) {
+		c.buf = make([][2]float64, len(samples))
+	}
+	want := util.Min(len(samples), c.len-c.pos)
+	n, _ := c.streamer.Stream(c.buf[:want])
+
+	for i, old := range c.buf[:n] {
+		t := float64(c.pos+i) / float64(c.len) * math.Pi / 2
+		in, out := math.Sin(t), math.Cos(t)
+		samples[i][0] = samples[i][0]*in + old[0]*out
+		samples[i][1] = samples[i][1]*in + old[1]*out
+	}
+	c.pos += n
+
+	// the crossfade also ends early if the song that is fading out does
+	if c.pos >= c.len || n < want {
+		s.stopCrossfadeLocked()
+	}
+}
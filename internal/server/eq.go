@@ -0,0 +1,33 @@
+package server
+
+import (
+	"sort"
+
+	"mtoohey.com/q/internal/eq"
+	"mtoohey.com/q/internal/protocol"
+)
+
+// setEQLocked switches to the equalizer preset with the given name, returning
+// false if there isn't one. sink and streamer should be locked before this
+// method is called.
+func (s *Server) setEQLocked(name protocol.EQState) bool {
+	preset, ok := s.eqPresets[name]
+	if !ok {
+		return false
+	}
+
+	s.eqPreset = name
+	s.equalizer = eq.New(preset, s.SampleRate)
+	return true
+}
+
+// getEQPresets returns the names of the available equalizer presets in
+// alphabetical order.
+func (s *Server) getEQPresets() []protocol.EQState {
+	names := make([]protocol.EQState, 0, len(s.eqPresets))
+	for name := range s.eqPresets {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,107 @@
+package server
+
+// fadeOutLocked makes the current song fade out instead of stopping abruptly
+// when it is interrupted, such as by seeking or skipping. It should be called
+// right before the interruption. A fade out of what would have played next
+// is rendered ahead of time, and whatever plays after it fades in. The fade
+// out is rendered at the song's original speed since it's so short, and what
+// it reads ahead is never heard, since the interruption moves away from it.
+// sink and streamer should be locked before this method is called.
+func (s *Server) fadeOutLocked() {
+	if s.fadeLen == 0 || len(s.fadeOut) > 0 {
+		// what plays after the pending fade out hasn't been heard yet, so
+		// it's enough for it to fade in
+		return
+	}
+
+	streaming := s.streamingLocked()
+
+	// continue from wherever the current fade is at, so that the gain
+	// doesn't jump
+	from := float64(s.fadePos) / float64(s.fadeLen)
+	s.fadePos = 0
+	s.pausing = false
+
+	// there's nothing to fade out while nothing is audible
+	if !streaming || s.streamer == nil || s.gapRemaining > 0 {
+		return
+	}
+
+	if s.fadeBuf == nil {
+		s.fadeBuf = make([][2]float64, s.fadeLen)
+	}
+	n, _ := s.streamer.Stream(s.fadeBuf)
+	s.mixCrossfadeLocked(s.fadeBuf[:n])
+
+	for i := range s.fadeBuf[:n] {
+		g := from * (1 - float64(i+1)/float64(s.fadeLen))
+		s.fadeBuf[i][0] *= g
+		s.fadeBuf[i][1] *= g
+	}
+	s.fadeOut = s.fadeBuf[:n]
+}
+
+// fadeToPauseLocked makes the current song fade out before pausing instead
+// of stopping abruptly. It should be called right before paused is set.
+// Unlike fadeOutLocked, nothing is read ahead: the songs keep streaming until
+// the fade out ends, so nothing is skipped when playback resumes. sink and
+// streamer should be locked before this method is called.
+func (s *Server) fadeToPauseLocked() {
+	// there's nothing to fade out while nothing is audible
+	s.pausing = s.fadePos > 0 && s.streamer != nil && s.gapRemaining == 0
+}
+
+// fadeInLocked makes whatever plays next fade in, continuing from wherever
+// the fade out before pausing is at if it hasn't ended yet. sink and streamer
+// should be locked before this method is called.
+func (s *Server) fadeInLocked() {
+	if !s.pausing {
+		s.fadePos = 0
+	}
+	s.pausing = false
+	s.stopAfterFade = false
+}
+
+// streamingLocked returns whether the songs are streamed, which they are
+// while playing and during the fade out before pausing. sink and streamer
+// should be locked before this method is called.
+func (s *Server) streamingLocked() bool {
+	s.pausedMu.RLock()
+	paused := s.paused
+	s.pausedMu.RUnlock()
+
+	return !bool(paused) || s.pausing
+}
+
+// streamFadeOutLocked streams the rest of the pending fade out, if there is
+// one, returning the number of samples it filled. sink and streamer should be
+// locked before this method is called.
+func (s *Server) streamFadeOutLocked(samples [][2]float64) int {
+	n := copy(samples, s.fadeOut)
+	s.fadeOut = s.fadeOut[n:]
+	return n
+}
+
+// applyFadeLocked ramps up the gain of samples if a fade in is in progress,
+// or ramps it down if the songs are fading out before pausing, which ends
+// once the gain reaches 0. sink and streamer should be locked before this
+// method is called.
+func (s *Server) applyFadeLocked(samples [][2]float64) {
+	for i := range samples {
+		var g float64
+		switch {
+		case s.pausing:
+			s.fadePos--
+			s.pausing = s.fadePos > 0
+			g = float64(s.fadePos) / float64(s.fadeLen)
+		case s.fadePos < s.fadeLen:
+			g = float64(s.fadePos) / float64(s.fadeLen)
+			s.fadePos++
+		default:
+			return
+		}
+
+		samples[i][0] *= g
+		samples[i][1] *= g
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"mtoohey.com/q/internal/eq"
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/server/queue"
+	"mtoohey.com/q/internal/server/sink"
+	"mtoohey.com/q/internal/testutil/assert"
+	"mtoohey.com/q/internal/track"
+)
+
+// sliceStreamSeekCloser streams a slice of samples.
+type sliceStreamSeekCloser struct {
+	samples [][2]float64
+	pos     int
+}
+
+func (s *sliceStreamSeekCloser) Stream(samples [][2]float64) (n int, ok bool) {
+	n = copy(samples, s.samples[s.pos:])
+	s.pos += n
+	return n, n > 0
+}
+
+func (s *sliceStreamSeekCloser) Err() error       { return nil }
+func (s *sliceStreamSeekCloser) Len() int         { return len(s.samples) }
+func (s *sliceStreamSeekCloser) Position() int    { return s.pos }
+func (s *sliceStreamSeekCloser) Seek(p int) error { s.pos = p; return nil }
+func (s *sliceStreamSeekCloser) Close() error     { return nil }
+
+// newFadeTestServer returns a server that is playing a song of constant
+// samples, with fades that are 4 samples long.
+func newFadeTestServer() (*Server, *sliceStreamSeekCloser) {
+	samples := make([][2]float64, 100)
+	for i := range samples {
+		samples[i] = [2]float64{1, 1}
+	}
+	streamer := &sliceStreamSeekCloser{samples: samples}
+
+	return &Server{
+		fadeLen:   4,
+		fadePos:   4,
+		streamer:  streamer,
+		equalizer: &eq.Equalizer{},
+		volume:    protocol.MaxVolume,
+	}, streamer
+}
+
+// gains returns the left channel of samples.
+func gains(samples [][2]float64) []float64 {
+	g := make([]float64, len(samples))
+	for i, sample := range samples {
+		g[i] = sample[0]
+	}
+	return g
+}
+
+func TestFadeToPause(t *testing.T) {
+	s, streamer := newFadeTestServer()
+
+	out := make([][2]float64, 2)
+	s.streamLocked(out)
+	assert.Equal(t, []float64{1, 1}, gains(out))
+
+	s.setPausedLocked(true)
+	out = make([][2]float64, 8)
+	n, ok := s.streamLocked(out)
+	assert.Equal(t, 8, n)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{0.75, 0.5, 0.25, 0, 0, 0, 0, 0}, gains(out))
+	// only the fade out is streamed, so nothing is skipped
+	assert.Equal(t, 6, streamer.Position())
+
+	s.streamLocked(out)
+	assert.Equal(t, 6, streamer.Position())
+
+	s.setPausedLocked(false)
+	out = make([][2]float64, 6)
+	s.streamLocked(out)
+	assert.Equal(t, []float64{0, 0.25, 0.5, 0.75, 1, 1}, gains(out))
+	assert.Equal(t, 12, streamer.Position())
+}
+
+func TestResumeWhileFadingToPause(t *testing.T) {
+	s, streamer := newFadeTestServer()
+
+	s.setPausedLocked(true)
+	out := make([][2]float64, 2)
+	s.streamLocked(out)
+	assert.Equal(t, []float64{0.75, 0.5}, gains(out))
+
+	// the fade in continues from where the fade out was
+	s.setPausedLocked(false)
+	out = make([][2]float64, 3)
+	s.streamLocked(out)
+	assert.Equal(t, []float64{0.5, 0.75, 1}, gains(out))
+	assert.Equal(t, 5, streamer.Position())
+}
+
+func TestStopAfterFade(t *testing.T) {
+	s, _ := newFadeTestServer()
+	s.logger = log.New(io.Discard, "", 0)
+	s.sink = sink.NewNull(44100, false)
+	s.queue = queue.QueueFrom([]*track.Track{{Path: "song"}})
+
+	stopped := func() bool {
+		s.stoppedMu.Lock()
+		defer s.stoppedMu.Unlock()
+		return bool(s.stopped)
+	}
+
+	// the output isn't released until the fade out has been streamed
+	s.handle(protocol.StopState(true), nil)
+	assert.False(t, stopped())
+
+	_, _ = s.Stream(make([][2]float64, 8))
+	deadline := time.Now().Add(time.Second)
+	for !stopped() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, stopped())
+}
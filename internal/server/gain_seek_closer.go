@@ -0,0 +1,54 @@
+package server
+
+import (
+	"math"
+
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/track"
+
+	"github.com/faiface/beep"
+)
+
+// gainStreamSeekCloser wraps a beep.StreamSeekCloser, multiplying the samples
+// it streams by a gain that can be changed while it is playing.
+type gainStreamSeekCloser struct {
+	beep.StreamSeekCloser
+	// gain is the linear gain to apply. The sink must be locked to modify
+	// it.
+	gain float64
+}
+
+func (g *gainStreamSeekCloser) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = g.StreamSeekCloser.Stream(samples)
+	if g.gain != 1 {
+		for i := range samples[:n] {
+			samples[i][0] *= g.gain
+			samples[i][1] *= g.gain
+		}
+	}
+	return n, ok
+}
+
+// replayGainFactor returns the linear gain that should be applied to a song
+// with the given ReplayGain values. If the preferred values for the mode are
+// missing, the others are used instead. The gain is reduced if it would cause
+// the song's peak to clip.
+func replayGainFactor(rg *track.ReplayGain, mode protocol.ReplayGainMode, shuffle protocol.ShuffleState, preamp float64) float64 {
+	if rg == nil || mode == protocol.ReplayGainModeOff {
+		return 1
+	}
+
+	album := mode == protocol.ReplayGainModeAlbum ||
+		mode == protocol.ReplayGainModeAuto && !bool(shuffle)
+
+	values := rg.Track
+	if album && rg.Album != nil || values == nil {
+		values = rg.Album
+	}
+
+	gain := math.Pow(10, (values.Gain+preamp)/20)
+	if values.Peak > 0 && gain*values.Peak > 1 {
+		gain = 1 / values.Peak
+	}
+	return gain
+}
@@ -8,52 +8,214 @@ import (
 	"mtoohey.com/q/internal/query"
 	"mtoohey.com/q/internal/track"
 	"mtoohey.com/q/internal/util"
-
-	"github.com/faiface/beep/speaker"
 )
 
 // handle handles a single incoming message.
 func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 	s.logger.Printf("received message of type %T: %#v", m, m)
 
+	// many messages can give us something to play
+	defer s.resumeIfActive()
+
 	switch m := m.(type) {
 	case protocol.PauseState:
-		speaker.Lock()
-		s.pausedMu.Lock()
-		s.paused = m
-		s.pausedMu.Unlock()
-		speaker.Unlock()
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.setPausedLocked(m)
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
 
 		s.broadcast(m)
 
+	case protocol.StopState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.setPausedLocked(protocol.PauseState(m))
+		// the output can't be released until the fade out has been heard,
+		// so streamLocked does it then instead
+		stopAfterFade := bool(m) && s.pausing
+		s.stopAfterFade = stopAfterFade
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(protocol.PauseState(m))
+		if bool(m) && !stopAfterFade {
+			s.stopIfIdle()
+		}
+
 	case protocol.RepeatState:
 		s.queueMu.Lock()
 		s.queue.Repeat = m
+		// the repeat state determines which song plays next
+		s.preloadNextLocked()
 		s.queueMu.Unlock()
 
 		s.broadcast(m)
 
+	case protocol.LoopState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		err := s.setLoopLocked(m)
+		m = s.loop
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		if err != nil {
+			respond(protocol.Error(fmt.Sprintf("failed to set loop: %s", err)))
+			return
+		}
+
+		s.broadcast(m)
+		s.broadcastProgress()
+
 	case protocol.ShuffleState:
+		s.sink.Lock()
 		s.queueMu.Lock()
+		s.streamerMu.Lock()
 		s.queue.Shuffle = m
+		// the auto ReplayGain mode depends on the shuffle state
+		s.updateGainLocked()
+		s.streamerMu.Unlock()
+		s.queueMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.ReplayGainMode:
+		s.sink.Lock()
+		s.queueMu.Lock()
+		s.streamerMu.Lock()
+		s.replayGainMode = m
+		s.updateGainLocked()
+		s.streamerMu.Unlock()
 		s.queueMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.CrossfadeState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.crossfadeDuration = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.VolumeState:
+		m = util.Min(m, protocol.MaxVolume)
+
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.volume = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.SpeedState:
+		m = util.Clamp(protocol.MinSpeed, m, protocol.MaxSpeed)
+
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.setSpeedLocked(m)
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.PreservePitchState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.preservePitch = m
+		s.resetSpeedLocked()
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.BalanceState:
+		m = util.Clamp(-protocol.MaxBalance, m, protocol.MaxBalance)
+
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.balance = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.MonoState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.mono = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.SwapChannelsState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.swapChannels = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.NightModeState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		if m && !s.nightMode {
+			// don't carry over gain reduction from before
+			s.compressor.Reset()
+		}
+		s.nightMode = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		s.broadcast(m)
+
+	case protocol.EQState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		ok := s.setEQLocked(m)
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
+
+		if !ok {
+			respond(protocol.Error(fmt.Sprintf(`unknown equalizer preset "%s"`, m)))
+			return
+		}
+
+		s.broadcast(m)
+
+	case protocol.MuteState:
+		s.sink.Lock()
+		s.streamerMu.Lock()
+		s.mute = m
+		s.streamerMu.Unlock()
+		s.sink.Unlock()
 
 		s.broadcast(m)
 
 	case protocol.Skip:
-		speaker.Lock()
+		s.sink.Lock()
 		s.queueMu.Lock()
 		s.streamerMu.Lock()
 
+		s.manualCrossfadeLocked()
 		s.skipLocked(int(m))
+		s.resetSpeedLocked()
 
 		s.streamerMu.Unlock()
 		s.queueMu.Unlock()
-		speaker.Unlock()
+		s.sink.Unlock()
 
 	case protocol.Seek:
-		speaker.Lock()
+		s.sink.Lock()
 		s.streamerMu.Lock()
+		s.fadeOutLocked()
 		if err := s.streamer.Seek(util.Clamp(
 			0,
 			s.format.SampleRate.N(time.Duration(m)),
@@ -64,8 +226,9 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 			s.dropTopLocked()
 			s.queueMu.Unlock()
 		}
+		s.resetSpeedLocked()
 		s.streamerMu.Unlock()
-		speaker.Unlock()
+		s.sink.Unlock()
 		// must come after streamerMu.unlock because this needs to RLock
 		// streamerMu.
 		s.broadcastProgress()
@@ -80,11 +243,14 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		}
 
 		if m == 0 {
-			speaker.Lock()
+			s.sink.Lock()
 			s.streamerMu.Lock()
+			s.fadeOutLocked()
 			s.playQueueTopLocked() // broadcasts new now playing
 			s.streamerMu.Unlock()
-			speaker.Unlock()
+			s.sink.Unlock()
+		} else {
+			s.preloadNextLocked()
 		}
 
 		newQueue := s.getQueueLocked()
@@ -97,11 +263,12 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		s.queueMu.Lock()
 		s.queue.Clear()
 
-		speaker.Lock()
+		s.sink.Lock()
 		s.streamerMu.Lock()
+		s.fadeOutLocked()
 		s.playQueueTopLocked() // broadcasts new now playing
 		s.streamerMu.Unlock()
-		speaker.Unlock()
+		s.sink.Unlock()
 
 		s.queueMu.Unlock()
 
@@ -116,11 +283,14 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		}
 
 		if m.Index == 0 {
-			speaker.Lock()
+			s.sink.Lock()
 			s.streamerMu.Lock()
+			s.fadeOutLocked()
 			s.playQueueTopLocked() // broadcasts new now playing
 			s.streamerMu.Unlock()
-			speaker.Unlock()
+			s.sink.Unlock()
+		} else {
+			s.preloadNextLocked()
 		}
 
 		newQueue := s.getQueueLocked()
@@ -146,11 +316,12 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 			// we don't re-shuffle the now playing song, so we're shuffling
 			// s.queue[1:], and if that's only 1 long, it's not going to have
 			// any effect
-
+			s.queueMu.Unlock()
 			return
 		}
 
 		s.queue.ReshuffleAfter(1)
+		s.preloadNextLocked()
 
 		newQueue := s.getQueueLocked()
 		s.queueMu.Unlock()
@@ -163,11 +334,14 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		s.queue.ReshuffleAfter(uint(m))
 
 		if m == 0 {
-			speaker.Lock()
+			s.sink.Lock()
 			s.streamerMu.Lock()
+			s.fadeOutLocked()
 			s.playQueueTopLocked() // broadcasts new now playing
 			s.streamerMu.Unlock()
-			speaker.Unlock()
+			s.sink.Unlock()
+		} else {
+			s.preloadNextLocked()
 		}
 
 		newQueue := s.getQueueLocked()
@@ -185,11 +359,14 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		}
 
 		if m == 0 {
-			speaker.Lock()
+			s.sink.Lock()
 			s.streamerMu.Lock()
+			s.fadeOutLocked()
 			s.playQueueTopLocked() // broadcasts new now playing
 			s.streamerMu.Unlock()
-			speaker.Unlock()
+			s.sink.Unlock()
+		} else {
+			s.preloadNextLocked()
 		}
 
 		newQueue := s.getQueueLocked()
@@ -211,11 +388,13 @@ func (s *Server) handle(m protocol.Message, respond func(protocol.Message)) {
 		s.queue.Skip(int(m))
 		s.queue.Shuffle = oldShuffle
 
-		speaker.Lock()
+		s.sink.Lock()
 		s.streamerMu.Lock()
+		s.manualCrossfadeLocked()
 		s.playQueueTopLocked() // broadcasts new now playing
+		s.resetSpeedLocked()
 		s.streamerMu.Unlock()
-		speaker.Unlock()
+		s.sink.Unlock()
 
 		newQueue := s.getQueueLocked()
 		s.queueMu.Unlock()
@@ -9,8 +9,6 @@ import (
 
 	"mtoohey.com/q/internal/protocol"
 	"mtoohey.com/q/internal/server/channelconn"
-
-	"github.com/faiface/beep/speaker"
 )
 
 // ChannelConn returns a channel connection to this server. It should be called
@@ -33,13 +31,24 @@ func (s *Server) Serve() (err error) {
 
 	defer s.logger.Println("finished serve")
 
-	if err := speaker.Init(s.SampleRate, s.SampleRate.N(time.Millisecond*10)); err != nil {
+	// the sink may need to be closed even if it fails to start
+	defer func() {
+		closeErr := s.sink.Close()
+
+		if err == nil {
+			err = closeErr
+		} else if closeErr != nil {
+			s.logger.Printf("failed to close sink: %s", closeErr)
+		}
+	}()
+
+	sinkFailed := func(err error) {
+		s.broadcastErr(fmt.Errorf("sink failed: %w", err))
+	}
+	if err := s.sink.Play(s, sinkFailed); err != nil {
 		close(s.closed)
-		return fmt.Errorf("failed to initialize speaker: %w", err)
+		return fmt.Errorf("failed to start sink: %w", err)
 	}
-	defer speaker.Close()
-
-	speaker.Play(s)
 
 	s.logger.Println("starting playback")
 
@@ -139,6 +148,15 @@ func (s *Server) Serve() (err error) {
 		}
 	}()
 
+	// idle routine
+	if s.idleTimeout > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.stopWhenIdle()
+		}()
+	}
+
 	s.logger.Println("starting accept loop")
 
 	for {
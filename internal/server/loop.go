@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"math"
+
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/util"
+)
+
+// setLoopLocked sets the loop, seeking to its start if the current position is
+// outside of it, or clears it if l is the zero value. The end of the loop is
+// limited to the end of the song. It returns an error if l isn't valid. sink
+// and streamer should be locked before this method is called.
+func (s *Server) setLoopLocked(l protocol.LoopState) error {
+	if l == (protocol.LoopState{}) {
+		s.loop = l
+		return nil
+	}
+
+	if s.streamer == nil {
+		return fmt.Errorf("nothing is playing")
+	}
+
+	l.B = util.Min(l.B, s.format.SampleRate.D(s.streamer.Len()))
+	if l.A < 0 || !l.Active() {
+		return fmt.Errorf("loop start %s must be before loop end %s", l.A, l.B)
+	}
+
+	a := s.format.SampleRate.N(l.A)
+	b := s.format.SampleRate.N(l.B)
+	if pos := s.streamer.Position(); pos < a || pos >= b {
+		s.fadeOutLocked()
+		if err := s.streamer.Seek(a); err != nil {
+			return fmt.Errorf("seek failed: %w", err)
+		}
+		s.resetSpeedLocked()
+	}
+
+	s.loop = l
+	return nil
+}
+
+// clearLoopLocked clears the loop if there is one, broadcasting the change.
+// sink and streamer should be locked before this method is called.
+func (s *Server) clearLoopLocked() {
+	if s.loop == (protocol.LoopState{}) {
+		return
+	}
+
+	s.loop = protocol.LoopState{}
+	go s.broadcast(s.loop)
+}
+
+// streamCurrentLocked streams the current song, returning to the start of the
+// loop whenever its end is reached if there is one. It requires sink and
+// streamerMu to be locked, and streamer to be non-nil.
+func (s *Server) streamCurrentLocked(samples [][2]float64) (n int, ok bool) {
+	if !s.loop.Active() {
+		return s.streamer.Stream(samples)
+	}
+
+	a := s.format.SampleRate.N(s.loop.A)
+	b := s.format.SampleRate.N(s.loop.B)
+	// the number of samples we stream per sample of the song
+	ratio := float64(s.SampleRate) / float64(s.format.SampleRate)
+
+	for n < len(samples) {
+		pos := s.streamer.Position()
+		if pos >= b {
+			if err := s.streamer.Seek(a); err != nil {
+				go s.broadcastErr(fmt.Errorf("failed to return to loop start: %w", err))
+				s.clearLoopLocked()
+				m, ok := s.streamer.Stream(samples[n:])
+				return n + m, ok
+			}
+			continue
+		}
+
+		want := util.Min(len(samples)-n, int(math.Ceil(float64(b-pos)*ratio)))
+		m, ok := s.streamer.Stream(samples[n : n+want])
+		n += m
+		if !ok || m == 0 {
+			// the song ended before the end of the loop
+			return n, ok
+		}
+	}
+
+	return n, true
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"mtoohey.com/q/internal/cmd"
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/testutil/assert"
+
+	"github.com/faiface/beep"
+)
+
+func TestStreamLoop(t *testing.T) {
+	samples := make([][2]float64, 10)
+	for i := range samples {
+		samples[i] = [2]float64{float64(i), float64(i)}
+	}
+	streamer := &sliceStreamSeekCloser{samples: samples, pos: 3}
+
+	s := &Server{
+		Globals:  cmd.Globals{SampleRate: 10},
+		streamer: streamer,
+		format:   beep.Format{SampleRate: 10},
+		loop:     protocol.LoopState{A: 200 * time.Millisecond, B: 500 * time.Millisecond},
+	}
+
+	// playback returns to A whenever it reaches B
+	out := make([][2]float64, 8)
+	n, ok := s.streamCurrentLocked(out)
+	assert.Equal(t, 8, n)
+	assert.True(t, ok)
+	assert.Equal(t, [][2]float64{{3, 3}, {4, 4}, {2, 2}, {3, 3}, {4, 4}, {2, 2}, {3, 3}, {4, 4}}, out)
+	assert.Equal(t, 5, streamer.Position())
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"mtoohey.com/q/internal/track"
+
+	"github.com/faiface/beep"
+)
+
+// preload is a song that is decoded in the background ahead of time, so that
+// it can start playing without delay once the current song finishes.
+type preload struct {
+	track *track.Track
+	// done is closed once decoding has finished, after which streamer,
+	// trim, format, and err may be read.
+	done     chan struct{}
+	streamer beep.StreamSeekCloser
+	trim     *trimStreamSeekCloser
+	format   beep.Format
+	err      error
+}
+
+// discard closes the preload's streamer once it has finished decoding, since it
+// won't be played.
+func (p *preload) discard() {
+	go func() {
+		<-p.done
+		if p.err == nil {
+			_ = p.streamer.Close() // intentionally ignore close error
+		}
+	}()
+}
+
+// decode decodes t, resampling it to the player's sample rate if necessary.
+// The returned format is that of t itself, and the returned trim skips t's
+// silence before it is resampled, so its positions are in t's sample rate.
+func (s *Server) decode(t *track.Track) (beep.StreamSeekCloser, *trimStreamSeekCloser, beep.Format, error) {
+	raw, format, err := t.Decode()
+	if err != nil {
+		return nil, nil, beep.Format{}, err
+	}
+	trim := &trimStreamSeekCloser{StreamSeekCloser: raw}
+
+	if format.SampleRate == s.SampleRate {
+		// if the raw streamer's sample rate is equal to the current sample
+		// rate, just use it directly without resampling
+		return trim, trim, format, nil
+	}
+
+	return resampleSeekCloser(format.SampleRate, s.SampleRate, trim), trim, format, nil
+}
+
+// preloadNextLocked starts decoding the song that will play after the current
+// one in the background, discarding any previously preloaded song that won't
+// play next anymore. It should be called whenever the queue changes in a way
+// that may affect which song plays next. queue should be locked before this
+// method is called.
+func (s *Server) preloadNextLocked() {
+	next, ok := s.queue.Next()
+
+	s.preloadMu.Lock()
+	defer s.preloadMu.Unlock()
+
+	if s.preload != nil {
+		if ok && s.preload.track == next {
+			return
+		}
+
+		s.preload.discard()
+		s.preload = nil
+	}
+
+	if !ok {
+		return
+	}
+
+	p := &preload{track: next, done: make(chan struct{})}
+	s.preload = p
+	go func() {
+		defer close(p.done)
+		p.streamer, p.trim, p.format, p.err = s.decode(next)
+	}()
+}
+
+// decodePreloaded decodes t, using the preloaded streamer if t was preloaded.
+// If it is still being decoded, this waits for it to finish, since that won't
+// take any longer than starting over.
+func (s *Server) decodePreloaded(t *track.Track) (beep.StreamSeekCloser, *trimStreamSeekCloser, beep.Format, error) {
+	s.preloadMu.Lock()
+	p := s.preload
+	if p == nil || p.track != t {
+		s.preloadMu.Unlock()
+		return s.decode(t)
+	}
+	s.preload = nil
+	s.preloadMu.Unlock()
+
+	<-p.done
+	return p.streamer, p.trim, p.format, p.err
+}
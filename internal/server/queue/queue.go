@@ -98,6 +98,26 @@ func (q Queue[T]) Head() (v T, ok bool) {
 	return q.head.value, true
 }
 
+// Next returns the track that will be at the top of the queue after skipping
+// forward by one, and true if there will be one. If the queue will be empty, it
+// returns the zero value of T and false.
+func (q Queue[T]) Next() (v T, ok bool) {
+	switch {
+	case q.Empty(), q.Repeat == protocol.RepeatStateNone && q.len == 1:
+		var z T
+		return z, false
+
+	case q.Repeat == protocol.RepeatStateTrack:
+		return q.head.value, true
+
+	default:
+		// Even when shuffling, the track being skipped is re-inserted
+		// somewhere other than its successor's position, so its successor
+		// always becomes the new head.
+		return q.head.next.value, true
+	}
+}
+
 // Skip moves the queue n tracks forward (or backward).
 //
 // Note that depending on the prior states of the queue, skipping backwards may
@@ -69,6 +69,55 @@ func TestQueue_Head(t *testing.T) {
 	})
 }
 
+func TestQueue_Next(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		actual, actualOk := Queue[int]{}.Next()
+		assert.False(t, actualOk)
+		assert.Zero(t, actual)
+	})
+
+	t.Run("repeat none, last", func(t *testing.T) {
+		actual, actualOk := QueueFrom([]int{3}).Next()
+		assert.False(t, actualOk)
+		assert.Zero(t, actual)
+	})
+
+	t.Run("repeat none", func(t *testing.T) {
+		actual, actualOk := QueueFrom([]int{3, 7, 5}).Next()
+		assert.True(t, actualOk)
+		assert.Equal(t, 7, actual)
+	})
+
+	t.Run("repeat track", func(t *testing.T) {
+		q := QueueFrom([]int{3, 7, 5})
+		q.Repeat = protocol.RepeatStateTrack
+		actual, actualOk := q.Next()
+		assert.True(t, actualOk)
+		assert.Equal(t, 3, actual)
+	})
+
+	t.Run("repeat queue, shuffle", func(t *testing.T) {
+		q := QueueFrom([]int{3, 7, 5, 1, 8})
+		q.Repeat = protocol.RepeatStateQueue
+		q.Shuffle = true
+		for i := 0; i < 20; i++ {
+			expected, expectedOk := q.Next()
+			q.Skip(1)
+			actual, actualOk := q.Head()
+			assert.Equal(t, expectedOk, actualOk)
+			assert.Equal(t, expected, actual)
+		}
+	})
+
+	t.Run("repeat queue, last", func(t *testing.T) {
+		q := QueueFrom([]int{3})
+		q.Repeat = protocol.RepeatStateQueue
+		actual, actualOk := q.Next()
+		assert.True(t, actualOk)
+		assert.Equal(t, 3, actual)
+	})
+}
+
 func TestQueue_Skip(t *testing.T) {
 	t.Run("skip 0", func(t *testing.T) {
 		q := QueueFrom([]int{8, 3, 9})
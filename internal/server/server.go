@@ -4,14 +4,20 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"mtoohey.com/q/internal/analysis"
 	"mtoohey.com/q/internal/cmd"
+	"mtoohey.com/q/internal/compressor"
+	"mtoohey.com/q/internal/eq"
 	"mtoohey.com/q/internal/protocol"
 	"mtoohey.com/q/internal/query"
 	"mtoohey.com/q/internal/server/channelconn"
 	"mtoohey.com/q/internal/server/queue"
+	"mtoohey.com/q/internal/server/sink"
 	"mtoohey.com/q/internal/server/unixsocketconn"
 	"mtoohey.com/q/internal/track"
+	"mtoohey.com/q/internal/util"
 
 	"github.com/faiface/beep"
 )
@@ -20,14 +26,47 @@ type Server struct {
 	// constants
 	cmd.Globals
 	logger *log.Logger
+	// preamp is the gain in dB that is applied in addition to ReplayGain
+	// values.
+	preamp float64
+	// crossfadeManual indicates whether manual skips and jumps should be
+	// crossfaded too.
+	crossfadeManual bool
+	// eqPresets contains the available equalizer presets.
+	eqPresets map[protocol.EQState]eq.Preset
+	// idleTimeout is how long the server has to be idle before it stops, or 0
+	// if it never stops.
+	idleTimeout time.Duration
+	// analyzeLoudness indicates whether the loudness of songs without
+	// ReplayGain tags should be analyzed.
+	analyzeLoudness bool
+	// trimSilence indicates whether the leading and trailing silence of songs
+	// should be skipped, and silenceThreshold is the level in dBFS below
+	// which samples are considered silent.
+	trimSilence      bool
+	silenceThreshold float64
+	// gap is the silence that is inserted between songs that play one after
+	// another.
+	gap time.Duration
+	// fadeLen is the length in samples of the fades when songs are
+	// interrupted, or 0 if they aren't faded.
+	fadeLen int
+	// coverNames are the names of image files that are used as the cover of
+	// songs in the same directory without an embedded one, in order of
+	// priority.
+	coverNames []string
 
 	// state
-	// pausedMu protects pause. speaker also needs to be locked when we modify
+	// pausedMu protects pause. sink also needs to be locked when we modify
 	// pause, but there are cases (such as when broadcasting an updated status)
-	// where we want to read paused without locking the speaker, so we have
+	// where we want to read paused without locking the sink, so we have
 	// this too...
 	pausedMu sync.RWMutex
 	paused   protocol.PauseState
+	// stoppedMu protects stopped, and is held while the sink is being
+	// suspended or resumed.
+	stoppedMu sync.Mutex
+	stopped   protocol.StopState
 	// queueMu protects queue.
 	queueMu sync.RWMutex
 	// shuffleIdx is the index within the queue of the first song that was
@@ -36,11 +75,77 @@ type Server struct {
 	queue queue.Queue[*track.Track]
 
 	// resources
+	// sink plays the output of the server. It must be locked to read from or
+	// modify the streamers that make up that output.
+	sink sink.Sink
 	// streamerMu protects format and streamer. Reads from, seeks of, and
-	// reassignments of streamer also require the speaker to be locked.
+	// reassignments of streamer also require the sink to be locked.
 	streamerMu sync.RWMutex
 	streamer   beep.StreamSeekCloser
 	format     beep.Format
+	// gain applies ReplayGain to streamer, and is nil when streamer is. It is
+	// also protected by streamerMu.
+	gain *gainStreamSeekCloser
+	// trim skips the silence of streamer, and is nil when streamer is. It is
+	// also protected by streamerMu.
+	trim *trimStreamSeekCloser
+	// gapRemaining is the number of samples of the gap before streamer that
+	// haven't been streamed yet. It is also protected by streamerMu.
+	gapRemaining int
+	// loop is also protected by streamerMu.
+	loop protocol.LoopState
+	// fadeOut is the rest of the fade out that plays before anything else,
+	// and fadeBuf is reused to store it. fadePos is the number of samples of
+	// the fade in after it that have been streamed, out of fadeLen. pausing
+	// indicates that the songs are fading out before pausing instead, in
+	// which case fadePos counts down, and they keep streaming until it
+	// reaches 0 even though paused is already set. stopAfterFade indicates
+	// that the output should be released once the fade out has been
+	// streamed. They are also protected by streamerMu.
+	fadeOut, fadeBuf [][2]float64
+	fadePos          int
+	pausing          bool
+	stopAfterFade    bool
+	// replayGainMode is also protected by streamerMu.
+	replayGainMode protocol.ReplayGainMode
+	// crossfadeDuration is also protected by streamerMu.
+	crossfadeDuration protocol.CrossfadeState
+	// crossfade is the crossfade in progress, or nil if there isn't one. It
+	// is also protected by streamerMu, and the sink must be locked to
+	// modify it.
+	crossfade *crossfade
+	// volume and mute are also protected by streamerMu.
+	volume protocol.VolumeState
+	mute   protocol.MuteState
+	// speed and preservePitch are also protected by streamerMu.
+	speed         protocol.SpeedState
+	preservePitch protocol.PreservePitchState
+	// speedStreamer changes the speed of the songs, and is nil if speed is 1.
+	// It is also protected by streamerMu, and the sink must be locked to
+	// use it.
+	speedStreamer speedStreamer
+	// eqPreset is also protected by streamerMu.
+	eqPreset protocol.EQState
+	// equalizer applies eqPreset, and is also protected by streamerMu.
+	equalizer *eq.Equalizer
+	// balance, mono, and swapChannels are also protected by streamerMu.
+	balance      protocol.BalanceState
+	mono         protocol.MonoState
+	swapChannels protocol.SwapChannelsState
+	// nightMode is also protected by streamerMu.
+	nightMode protocol.NightModeState
+	// compressor is applied while nightMode is enabled. It is also protected
+	// by streamerMu.
+	compressor *compressor.Compressor
+	// preloadMu protects preload. If streamerMu also needs to be locked, it
+	// must be locked first.
+	preloadMu sync.Mutex
+	// preload is the song that will play after the current one, which is
+	// decoded ahead of time. It is nil if no song will play next.
+	preload *preload
+	// analyses contains the results of analyzing songs. It is nil if neither
+	// loudness analysis nor silence trimming is enabled.
+	analyses *analysis.Cache
 
 	channelListener *channelconn.ChannelListener
 	listeners       []protocol.Listener
@@ -65,9 +170,71 @@ func NewServer(cmd Cmd, g cmd.Globals, logger *log.Logger) (*Server, error) {
 	// function is running in, so there is no danger of races or other issues.
 
 	s := &Server{
-		Globals: g,
-		logger:  logger,
-		paused:  false,
+		Globals:           g,
+		logger:            logger,
+		preamp:            cmd.Preamp,
+		crossfadeManual:   cmd.CrossfadeManual,
+		idleTimeout:       cmd.IdleTimeout,
+		analyzeLoudness:   cmd.Analyze,
+		trimSilence:       cmd.TrimSilence,
+		silenceThreshold:  cmd.SilenceThreshold,
+		gap:               cmd.Gap,
+		fadeLen:           g.SampleRate.N(cmd.Fade),
+		fadePos:           g.SampleRate.N(cmd.Fade),
+		coverNames:        cmd.CoverNames,
+		paused:            false,
+		replayGainMode:    cmd.ReplayGain,
+		crossfadeDuration: cmd.Crossfade,
+		volume:            cmd.Volume,
+		mute:              cmd.Mute,
+		speed:             cmd.Speed,
+		nightMode:         cmd.NightMode,
+		balance:           cmd.Balance,
+		mono:              cmd.Mono,
+		swapChannels:      cmd.SwapChannels,
+		preservePitch:     cmd.PreservePitch,
+	}
+	s.resetSpeedLocked()
+	s.compressor = compressor.New(compressor.Settings{
+		Threshold: cmd.NightModeThreshold,
+		Ratio:     cmd.NightModeRatio,
+		Attack:    cmd.NightModeAttack,
+		Release:   cmd.NightModeRelease,
+	}, s.SampleRate)
+
+	switch cmd.Sink {
+	case "speaker":
+		s.sink = sink.NewSpeaker(s.SampleRate)
+	case "null":
+		s.sink = sink.NewNull(s.SampleRate, cmd.SinkRealtime)
+	case "wav":
+		if cmd.SinkPath == nil {
+			return nil, fmt.Errorf("the wav sink requires --sink-path")
+		}
+		s.sink = sink.NewWAV(*cmd.SinkPath, s.SampleRate, cmd.SinkRealtime)
+	case "pcm":
+		s.sink = sink.NewPCM(cmd.SinkPath, s.SampleRate, cmd.SinkRealtime)
+	default:
+		return nil, fmt.Errorf(`unknown sink "%s"`, cmd.Sink)
+	}
+
+	presets, err := eq.LoadPresets(cmd.EQPresets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load equalizer presets: %w", err)
+	}
+	s.eqPresets = map[protocol.EQState]eq.Preset{}
+	for name, p := range presets {
+		s.eqPresets[protocol.EQState(name)] = p
+	}
+	if !s.setEQLocked(cmd.EQ) {
+		return nil, fmt.Errorf(`unknown equalizer preset "%s"`, cmd.EQ)
+	}
+
+	if cmd.Analyze || cmd.TrimSilence {
+		var err error
+		if s.analyses, err = analysis.OpenCache(g.LoudnessCache); err != nil {
+			return nil, fmt.Errorf("failed to open analysis cache: %w", err)
+		}
 	}
 
 	pathSet := map[string]struct{}{}
@@ -124,7 +291,7 @@ func (s *Server) Close() error {
 	return nil
 }
 
-// Stream requires speaker to be locked, which beep will do automatically.
+// Stream requires the sink to be locked, which it will do automatically.
 func (s *Server) Stream(samples [][2]float64) (n int, ok bool) {
 	s.streamerMu.Lock()
 	n, ok = s.streamLocked(samples)
@@ -132,19 +299,142 @@ func (s *Server) Stream(samples [][2]float64) (n int, ok bool) {
 	return
 }
 
-// streamLocked requires speaker and streamerMu to be locked.
+// setPausedLocked pauses or resumes playback, fading out or in if the pause
+// state changes. sink and streamer should be locked before this method is
+// called.
+func (s *Server) setPausedLocked(paused protocol.PauseState) {
+	s.pausedMu.RLock()
+	wasPaused := s.paused
+	s.pausedMu.RUnlock()
+
+	if paused == wasPaused {
+		return
+	}
+
+	if paused {
+		s.fadeToPauseLocked()
+	}
+
+	s.pausedMu.Lock()
+	s.paused = paused
+	s.pausedMu.Unlock()
+
+	if !paused {
+		s.fadeInLocked()
+	}
+}
+
+// streamLocked requires sink and streamerMu to be locked.
 func (s *Server) streamLocked(samples [][2]float64) (n int, ok bool) {
+	// the pending fade out plays before anything else
+	f := s.streamFadeOutLocked(samples)
+
+	// only the rest of the fade out before pausing is streamed, so that
+	// playback resumes right where it faded out
+	end := len(samples)
+	if s.pausing {
+		end = f + util.Min(end-f, s.fadePos)
+	}
+
+	// the speed streamer isn't used while paused so that it doesn't drop the
+	// samples it has buffered
+	if s.streamingLocked() && s.speedStreamer != nil {
+		n, ok = s.speedStreamer.Stream(samples[f:end])
+	} else {
+		n, ok = s.streamSongsLocked(samples[f:end])
+	}
+	s.applyFadeLocked(samples[f : f+n])
+	n += f
+
+	// the rest is silent once the fade out before pausing ends
+	if n == end && end < len(samples) {
+		m, _ := s.streamSongsLocked(samples[end:])
+		n += m
+	}
+
+	// everything that fades out has now been streamed, so stopping won't cut
+	// it off. stopIfIdle can't be called while the sink is locked.
+	if s.stopAfterFade && !s.pausing && len(s.fadeOut) == 0 {
+		s.stopAfterFade = false
+		go s.stopIfIdle()
+	}
+
+	s.equalizer.Process(samples[:n])
+	if s.nightMode {
+		s.compressor.Process(samples[:n])
+	}
+	s.applyChannelsLocked(samples[:n])
+
+	s.applyVolumeLocked(samples[:n])
+
+	return n, ok
+}
+
+// streamSongsLocked streams the songs in the queue, crossfading between them
+// if necessary, at their original speed. It requires sink and streamerMu
+// to be locked.
+func (s *Server) streamSongsLocked(samples [][2]float64) (n int, ok bool) {
+	streaming := s.streamingLocked()
+
+	if streaming {
+		s.maybeCrossfadeLocked()
+	}
+
+	n, ok = s.streamQueueLocked(samples)
+
+	if streaming {
+		s.mixCrossfadeLocked(samples[:n])
+	}
+
+	return n, ok
+}
+
+// applyVolumeLocked scales samples according to the volume and mute state.
+// streamerMu should be locked.
+func (s *Server) applyVolumeLocked(samples [][2]float64) {
+	if s.volume == protocol.MaxVolume && !s.mute {
+		return
+	}
+
+	gain := s.volume.Gain()
+	if s.mute {
+		gain = 0
+	}
+
+	for i := range samples {
+		samples[i][0] *= gain
+		samples[i][1] *= gain
+	}
+}
+
+// streamQueueLocked streams the songs in the queue, skipping to the next one
+// when the current one ends, and filling the rest of samples with silence if
+// there aren't any more. It requires sink and streamerMu to be locked.
+func (s *Server) streamQueueLocked(samples [][2]float64) (n int, ok bool) {
 	silenceFrom := 0
 
-	s.pausedMu.RLock()
-	paused := s.paused
-	s.pausedMu.RUnlock()
+	streaming := s.streamingLocked()
+
+	if streaming && s.streamer != nil && s.gapRemaining > 0 {
+		// stream the rest of the gap before the current song
+		gap := util.Min(s.gapRemaining, len(samples))
+		for i := range samples[:gap] {
+			samples[i] = [2]float64{}
+		}
+		s.gapRemaining -= gap
+
+		if gap < len(samples) {
+			n, _ := s.streamQueueLocked(samples[gap:])
+			gap += n
+		}
+		return gap, true
+	}
 
-	if !paused && s.streamer != nil {
+	if streaming && s.streamer != nil {
 		var ok bool
 		var err error
 
-		silenceFrom, ok = s.streamer.Stream(samples)
+		silenceFrom, ok = s.streamCurrentLocked(samples)
 		if !ok {
 			// if the streamer failed, warn and set err so that we'll skip
 			// below
@@ -166,10 +456,15 @@ func (s *Server) streamLocked(samples [][2]float64) (n int, ok bool) {
 			}
 			s.queueMu.Unlock()
 
+			// songs that play one after another are separated by the gap
+			if s.streamer != nil {
+				s.gapRemaining = s.SampleRate.N(s.gap)
+			}
+
 			// recursively continue streaming after the skip to avoid silence,
 			// if there's no now-playing song after the skip, the recurisve
 			// call will realize this and fill the rest of samples with silence
-			n, _ := s.streamLocked(samples[silenceFrom:])
+			n, _ := s.streamQueueLocked(samples[silenceFrom:])
 			silenceFrom += n
 		}
 	}
@@ -182,7 +477,7 @@ func (s *Server) streamLocked(samples [][2]float64) (n int, ok bool) {
 }
 
 func (*Server) Err() error {
-	// we never want the server to be drained from the speaker causing its
+	// we never want the server to be drained from the sink causing its
 	// playback to end, so we always return nil here, just as we always return
 	// true in (*server).Stream.
 
@@ -190,7 +485,7 @@ func (*Server) Err() error {
 }
 
 // dropTopLocked removes the song currently at the top of the queue, and moves
-// to the next one, if one exists. speaker, queue, and streamer should all be
+// to the next one, if one exists. sink, queue, and streamer should all be
 // locked before a call to this method.
 func (s *Server) dropTopLocked() {
 	_, ok := s.queue.Remove(0)
@@ -202,7 +497,7 @@ func (s *Server) dropTopLocked() {
 	go s.broadcast(s.getQueueLocked())
 }
 
-// skipLocked moves to the next song, if there is one. speaker, queue, and
+// skipLocked moves to the next song, if there is one. sink, queue, and
 // streamer should be locked before a call to this method.
 func (s *Server) skipLocked(n int) {
 	s.queue.Skip(n)
@@ -213,7 +508,7 @@ func (s *Server) skipLocked(n int) {
 
 // playQueueTopLocked closes the current streamer, then begins decoding the
 // item at s.queue[0], if one exists. If len(s.queue) == 0 the current streamer
-// is assigned to nil. speaker, queue, and streamer should be locked before
+// is assigned to nil. sink, queue, and streamer should be locked before
 // this method is called.
 func (s *Server) playQueueTopLocked() {
 	if s.streamer != nil {
@@ -225,7 +520,14 @@ func (s *Server) playQueueTopLocked() {
 	head, ok := s.queue.Head()
 	if !ok {
 		s.streamer = nil
+		s.gain = nil
+		s.trim = nil
+		s.gapRemaining = 0
 		s.format = beep.Format{}
+		s.clearLoopLocked()
+		// there's nothing to fade into
+		s.stopCrossfadeLocked()
+		s.preloadNextLocked()
 		s.broadcastNowPlayingLocked()
 
 		return
@@ -233,20 +535,74 @@ func (s *Server) playQueueTopLocked() {
 
 	var streamer beep.StreamSeekCloser
 	var err error
-	streamer, s.format, err = head.Decode()
+	streamer, s.trim, s.format, err = s.decodePreloaded(head)
 	if err != nil {
-		s.streamer, s.format = nil, beep.Format{}
+		s.streamer, s.gain, s.trim, s.format = nil, nil, nil, beep.Format{}
 		s.broadcastErr(fmt.Errorf("failed to decode queue[0]: %w", err))
 		s.dropTopLocked() // recursively calls playQueueTopLocked after dropping
 		return
 	}
-	if s.format.SampleRate == s.SampleRate {
-		// if the raw streamer's sample rate is equal to the current sample
-		// rate, just use it directly without resampling
-		s.streamer = streamer
-	} else {
-		s.streamer = resampleSeekCloser(s.format.SampleRate, s.SampleRate, streamer)
-	}
+
+	s.gain = &gainStreamSeekCloser{StreamSeekCloser: streamer, gain: 1}
+	s.streamer = s.gain
+	s.gapRemaining = 0
+	// the loop was a section of the previous song
+	s.clearLoopLocked()
+	s.updateGainLocked()
+	s.updateTrimLocked()
+	s.analyzeUpcomingLocked()
+	s.preloadNextLocked()
 
 	s.broadcastNowPlayingLocked()
 }
+
+// updateGainLocked updates the gain applied to the current streamer according
+// to the current ReplayGain mode. sink, queue, and streamer should be locked
+// before this method is called.
+func (s *Server) updateGainLocked() {
+	if s.gain == nil {
+		return
+	}
+
+	head, ok := s.queue.Head()
+	if !ok {
+		return
+	}
+
+	rg, err := head.ReplayGain()
+	if err != nil {
+		s.broadcastErr(fmt.Errorf("failed to get queue[0] replay gain: %w", err))
+	}
+	if rg == nil && s.analyzeLoudness {
+		// fall back to the analyzed loudness, if it's available yet
+		if r, ok := s.analyses.Get(head.Path); ok {
+			rg = &track.ReplayGain{Track: r.ReplayGain()}
+		}
+	}
+
+	s.gain.gain = replayGainFactor(rg, s.replayGainMode, s.queue.Shuffle, s.preamp)
+}
+
+// updateTrimLocked updates the silence skipped by the current streamer, if
+// silence trimming is enabled and the current song's silence has been detected
+// yet. sink, queue, and streamer should be locked before this method is
+// called.
+func (s *Server) updateTrimLocked() {
+	if s.trim == nil || !s.trimSilence {
+		return
+	}
+
+	head, ok := s.queue.Head()
+	if !ok {
+		return
+	}
+
+	r, ok := s.analyses.Get(head.Path)
+	if !ok || !r.HasSilence(s.silenceThreshold) || r.Silence.End <= r.Silence.Start {
+		// silent songs are left as they are rather than being skipped
+		return
+	}
+
+	s.trim.start = s.format.SampleRate.N(time.Duration(r.Silence.Start * float64(time.Second)))
+	s.trim.end = s.format.SampleRate.N(time.Duration(r.Silence.End * float64(time.Second)))
+}
@@ -0,0 +1,23 @@
+package sink
+
+import "github.com/faiface/beep"
+
+// Null is a sink that discards samples.
+type Null struct {
+	pump
+}
+
+// NewNull creates a Null sink that streams at sampleRate. If realtime is
+// false, samples are streamed as fast as possible instead.
+func NewNull(sampleRate beep.SampleRate, realtime bool) *Null {
+	return &Null{pump: pump{sampleRate: sampleRate, realtime: realtime}}
+}
+
+func (n *Null) Play(s beep.Streamer, fail func(error)) error {
+	n.start(s, func([][2]float64) error { return nil }, fail)
+	return nil
+}
+
+func (n *Null) Close() error {
+	return n.finish()
+}
@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/faiface/beep"
+)
+
+// appendPCM appends samples to b as interleaved, signed, 16-bit, little-endian
+// PCM.
+func appendPCM(b []byte, samples [][2]float64) []byte {
+	for _, s := range samples {
+		for _, v := range s {
+			v = math.Max(-1, math.Min(v, 1))
+			b = binary.LittleEndian.AppendUint16(b, uint16(int16(v*math.MaxInt16)))
+		}
+	}
+	return b
+}
+
+// PCM is a sink that writes raw, interleaved, signed, 16-bit, little-endian
+// PCM to a file, such as a FIFO, or to stdout.
+type PCM struct {
+	pump
+	path *string
+
+	f   *os.File
+	buf []byte
+}
+
+// NewPCM creates a PCM sink that writes to the file at path, or stdout if path
+// is nil, at sampleRate. The file is opened once playback begins, which
+// blocks until there is a reader if it is a FIFO. If realtime is false,
+// samples are written as fast as the reader accepts them instead.
+func NewPCM(path *string, sampleRate beep.SampleRate, realtime bool) *PCM {
+	return &PCM{pump: pump{sampleRate: sampleRate, realtime: realtime}, path: path}
+}
+
+func (p *PCM) Play(s beep.Streamer, fail func(error)) error {
+	if p.path == nil {
+		p.f = os.Stdout
+	} else {
+		var err error
+		p.f, err = os.OpenFile(*p.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output: %w", err)
+		}
+	}
+
+	p.start(s, p.write, fail)
+	return nil
+}
+
+func (p *PCM) write(samples [][2]float64) error {
+	p.buf = appendPCM(p.buf[:0], samples)
+	if _, err := p.f.Write(p.buf); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+func (p *PCM) Close() error {
+	err := p.finish()
+
+	// stdout isn't ours to close
+	if p.f != nil && p.f != os.Stdout {
+		if closeErr := p.f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close output: %w", closeErr)
+		}
+	}
+
+	return err
+}
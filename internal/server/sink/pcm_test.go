@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestAppendPCM(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  [][2]float64
+		expected []byte
+	}{
+		{
+			name:     "silence",
+			samples:  [][2]float64{{0, 0}},
+			expected: []byte{0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:     "full scale",
+			samples:  [][2]float64{{1, -1}},
+			expected: []byte{0xff, 0x7f, 0x01, 0x80},
+		},
+		{
+			name:     "half scale",
+			samples:  [][2]float64{{0.5, -0.5}},
+			expected: []byte{0xff, 0x3f, 0x01, 0xc0},
+		},
+		{
+			name:     "clipped",
+			samples:  [][2]float64{{2, -2}},
+			expected: []byte{0xff, 0x7f, 0x01, 0x80},
+		},
+		{
+			name:     "interleaved",
+			samples:  [][2]float64{{1, 0}, {0, 1}},
+			expected: []byte{0xff, 0x7f, 0x00, 0x00, 0x00, 0x00, 0xff, 0x7f},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, appendPCM(nil, tt.samples))
+		})
+	}
+}
+
+func TestAppendPCMAppends(t *testing.T) {
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x00, 0x00}, appendPCM([]byte{0x01}, [][2]float64{{0, 0}}))
+}
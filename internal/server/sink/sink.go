@@ -0,0 +1,139 @@
+// Package sink contains the outputs that a server can play its audio to.
+package sink
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// Sink plays the samples of a streamer.
+type Sink interface {
+	// Play starts playing s in the background. It should be called at most
+	// once. If playback stops because of an error, fail is called with it
+	// from the background, and playback doesn't restart until Resume is
+	// called.
+	Play(s beep.Streamer, fail func(error)) error
+	// Lock prevents the sink from streaming from s until Unlock is called, so
+	// that s can be modified safely. It may be called before Play.
+	Lock()
+	// Unlock allows the sink to stream from s again.
+	Unlock()
+	// Suspend stops playback and releases any device held by the sink until
+	// Resume is called. It should only be called after Play succeeds, and
+	// while the sink isn't locked.
+	Suspend() error
+	// Resume restarts playback after Suspend.
+	Resume() error
+	// Close stops playback and releases the resources of the sink. It may be
+	// called even if Play wasn't, or if it failed.
+	Close() error
+}
+
+// pumpBufferDuration is the duration of the samples that a pump streams at
+// once.
+const pumpBufferDuration = 10 * time.Millisecond
+
+// pump streams samples in the background and passes them to a function,
+// either in real time or as fast as possible.
+type pump struct {
+	sampleRate beep.SampleRate
+	realtime   bool
+
+	// mu is locked while streaming.
+	mu sync.Mutex
+	// s, write, and fail are the arguments of the last call to start.
+	s     beep.Streamer
+	write func(samples [][2]float64) error
+	fail  func(error)
+	// stop is closed to stop the pump, and done is closed once it has
+	// stopped. Both are nil if the pump was never started.
+	stop, done chan struct{}
+	// err is the error returned by write, if any, which has already been
+	// passed to fail. It must not be accessed until done is closed.
+	err error
+}
+
+func (p *pump) Lock() {
+	p.mu.Lock()
+}
+
+func (p *pump) Unlock() {
+	p.mu.Unlock()
+}
+
+// start starts streaming from s in the background, passing the samples to
+// write until it returns an error, which is passed to fail, or the pump is
+// stopped.
+func (p *pump) start(s beep.Streamer, write func(samples [][2]float64) error, fail func(error)) {
+	p.s, p.write, p.fail = s, write, fail
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		samples := make([][2]float64, p.sampleRate.N(pumpBufferDuration))
+		begin := time.Now()
+		var played int
+		for {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+
+			p.mu.Lock()
+			n, _ := s.Stream(samples)
+			p.mu.Unlock()
+			// fill the rest with silence, like the speaker does
+			clear(samples[n:])
+
+			if err := write(samples); err != nil {
+				p.err = err
+				fail(err)
+				return
+			}
+			played += len(samples)
+
+			if p.realtime {
+				select {
+				case <-time.After(time.Until(begin.Add(p.sampleRate.D(played)))):
+				case <-p.stop:
+					return
+				}
+			}
+		}
+	}()
+}
+
+// finish stops the pump if it was started, returning the error that stopped
+// it early, if any.
+func (p *pump) finish() error {
+	if p.stop == nil {
+		return nil
+	}
+
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+
+	return p.err
+}
+
+func (p *pump) Suspend() error {
+	// an error that stopped the pump has already been passed to fail, and
+	// doesn't prevent it from being suspended
+	_ = p.finish()
+	return nil
+}
+
+func (p *pump) Resume() error {
+	p.err = nil
+	p.start(p.s, p.write, p.fail)
+	return nil
+}
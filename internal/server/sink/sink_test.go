@@ -0,0 +1,30 @@
+package sink
+
+import (
+	"errors"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+
+	"github.com/faiface/beep"
+)
+
+func TestPumpWriteError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	failed := make(chan error, 2)
+
+	p := &pump{sampleRate: 44100}
+	p.start(beep.Silence(-1), func([][2]float64) error { return writeErr }, func(err error) {
+		failed <- err
+	})
+	assert.Equal(t, writeErr, <-failed)
+
+	// the error has already been reported, so it doesn't prevent suspending
+	assert.Zero(t, p.Suspend())
+
+	// resuming starts writing again, so the error is reported again
+	assert.Zero(t, p.Resume())
+	assert.Equal(t, writeErr, <-failed)
+	assert.Equal(t, writeErr, p.finish())
+	assert.Equal(t, 0, len(failed))
+}
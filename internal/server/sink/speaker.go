@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Speaker is a sink that plays to the default audio device.
+type Speaker struct {
+	sampleRate beep.SampleRate
+	// s is the streamer that is played.
+	s beep.Streamer
+}
+
+// NewSpeaker creates a Speaker that plays at sampleRate.
+func NewSpeaker(sampleRate beep.SampleRate) *Speaker {
+	return &Speaker{sampleRate: sampleRate}
+}
+
+func (sp *Speaker) Play(s beep.Streamer, fail func(error)) error {
+	sp.s = s
+	return sp.Resume()
+}
+
+func (sp *Speaker) Lock() {
+	speaker.Lock()
+}
+
+func (sp *Speaker) Unlock() {
+	speaker.Unlock()
+}
+
+func (sp *Speaker) Suspend() error {
+	speaker.Close()
+	return nil
+}
+
+func (sp *Speaker) Resume() error {
+	if err := speaker.Init(sp.sampleRate, sp.sampleRate.N(time.Millisecond*10)); err != nil {
+		return fmt.Errorf("failed to initialize speaker: %w", err)
+	}
+
+	speaker.Play(sp.s)
+	return nil
+}
+
+func (sp *Speaker) Close() error {
+	speaker.Close()
+	return nil
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/faiface/beep"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// wavHeaderSize is the size of the header written by WAV, which is followed by
+// the samples.
+const wavHeaderSize = 44
+
+// WAV is a sink that records to a WAV file, see
+// http://soundfile.sapp.org/doc/WaveFormat/.
+type WAV struct {
+	pump
+	path string
+
+	f *os.File
+	// dataSize is the number of bytes of samples that have been written.
+	dataSize int64
+	buf      []byte
+}
+
+// NewWAV creates a WAV sink that records to the file at path, at sampleRate.
+// The file is created once playback begins, and its header is completed when
+// the sink is closed. If realtime is false, samples are written as fast as
+// possible instead.
+func NewWAV(path string, sampleRate beep.SampleRate, realtime bool) *WAV {
+	return &WAV{pump: pump{sampleRate: sampleRate, realtime: realtime}, path: path}
+}
+
+func (w *WAV) Play(s beep.Streamer, fail func(error)) error {
+	var err error
+	w.f, err = os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+
+	// the sizes are filled in once we know them
+	if _, err := w.f.Write(w.header()); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	w.start(s, w.write, fail)
+	return nil
+}
+
+// header returns the header of the file given the current data size, which
+// is capped at the largest size that the header can represent.
+func (w *WAV) header() []byte {
+	const (
+		channels  = 2
+		precision = 2
+	)
+
+	dataSize := uint32(util.Min(w.dataSize, math.MaxUint32-wavHeaderSize+8))
+
+	b := make([]byte, 0, wavHeaderSize)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, wavHeaderSize-8+dataSize)
+	b = append(b, "WAVE"...)
+
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, channels)
+	b = binary.LittleEndian.AppendUint32(b, uint32(w.sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(w.sampleRate)*channels*precision)
+	b = binary.LittleEndian.AppendUint16(b, channels*precision)
+	b = binary.LittleEndian.AppendUint16(b, precision*8)
+
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, dataSize)
+	return b
+}
+
+func (w *WAV) write(samples [][2]float64) error {
+	w.buf = appendPCM(w.buf[:0], samples)
+	if _, err := w.f.Write(w.buf); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	w.dataSize += int64(len(w.buf))
+	return nil
+}
+
+func (w *WAV) Close() error {
+	err := w.finish()
+	if w.f == nil {
+		return err
+	}
+
+	if _, headerErr := w.f.WriteAt(w.header(), 0); err == nil && headerErr != nil {
+		err = fmt.Errorf("failed to write header: %w", headerErr)
+	}
+	if closeErr := w.f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output: %w", closeErr)
+	}
+
+	return err
+}
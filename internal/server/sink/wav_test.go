@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+
+	"github.com/faiface/beep"
+)
+
+func TestWAVHeader(t *testing.T) {
+	w := NewWAV("", 44100, false)
+	w.dataSize = 1000
+
+	expected := []byte{
+		'R', 'I', 'F', 'F', 0x0c, 0x04, 0x00, 0x00, 'W', 'A', 'V', 'E',
+		'f', 'm', 't', ' ', 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00,
+		0x44, 0xac, 0x00, 0x00, 0x10, 0xb1, 0x02, 0x00, 0x04, 0x00, 0x10, 0x00,
+		'd', 'a', 't', 'a', 0xe8, 0x03, 0x00, 0x00,
+	}
+	assert.Equal(t, expected, w.header())
+}
+
+func TestWAVHeaderCapped(t *testing.T) {
+	w := NewWAV("", 44100, false)
+	w.dataSize = 1 << 33
+
+	b := w.header()
+	assert.Equal(t, uint32(0xffffffff), binary.LittleEndian.Uint32(b[4:8]))
+	assert.Equal(t, uint32(0xffffffff-wavHeaderSize+8), binary.LittleEndian.Uint32(b[40:44]))
+}
+
+func TestWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w := NewWAV(path, 44100, false)
+
+	// close the sink once something has been streamed
+	var once sync.Once
+	streamed := make(chan struct{})
+	assert.Zero(t, w.Play(beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			samples[i] = [2]float64{0.5, -0.5}
+		}
+		once.Do(func() { close(streamed) })
+		return len(samples), true
+	}), func(err error) { t.Error(err) }))
+	<-streamed
+	assert.Zero(t, w.Close())
+
+	b, err := os.ReadFile(path)
+	assert.Zero(t, err)
+	if !assert.True(t, len(b) > wavHeaderSize) {
+		return
+	}
+
+	// the sizes are filled in when the sink is closed
+	assert.Equal(t, "RIFF", string(b[0:4]))
+	assert.Equal(t, uint32(len(b)-8), binary.LittleEndian.Uint32(b[4:8]))
+	assert.Equal(t, "data", string(b[36:40]))
+	assert.Equal(t, uint32(len(b)-wavHeaderSize), binary.LittleEndian.Uint32(b[40:44]))
+	assert.Equal(t, 0, (len(b)-wavHeaderSize)%4)
+	assert.Equal(t, []byte{0xff, 0x3f, 0x01, 0xc0}, b[wavHeaderSize:wavHeaderSize+4])
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"mtoohey.com/q/internal/protocol"
+	"mtoohey.com/q/internal/stretch"
+
+	"github.com/faiface/beep"
+)
+
+// speedResampleQuality is the quality used to resample songs to change their
+// speed when their pitch isn't preserved.
+const speedResampleQuality = 4
+
+// speedStreamer changes the speed of the samples from another streamer,
+// buffering some of them.
+type speedStreamer interface {
+	beep.Streamer
+	SetRatio(ratio float64)
+}
+
+// setSpeedLocked changes the speed, keeping the samples buffered by the speed
+// streamer if there is one. sink and streamer should be locked before this
+// method is called.
+func (s *Server) setSpeedLocked(speed protocol.SpeedState) {
+	s.speed = speed
+
+	if s.speedStreamer == nil || speed == 1 {
+		s.resetSpeedLocked()
+		return
+	}
+
+	s.speedStreamer.SetRatio(float64(speed))
+}
+
+// resetSpeedLocked replaces the speed streamer according to the speed and
+// preserve pitch state, dropping the samples it has buffered. This should be
+// done when switching to a different position or song, so that the buffered
+// samples of the old one aren't heard. sink and streamer should be locked
+// before this method is called.
+func (s *Server) resetSpeedLocked() {
+	if s.speed == 1 {
+		s.speedStreamer = nil
+		return
+	}
+
+	source := beep.StreamerFunc(s.streamSongsLocked)
+	if s.preservePitch {
+		s.speedStreamer = stretch.New(source, s.SampleRate, float64(s.speed))
+	} else {
+		s.speedStreamer = beep.ResampleRatio(speedResampleQuality, float64(s.speed), source)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"mtoohey.com/q/internal/protocol"
+)
+
+// idleCheckInterval is how often stopWhenIdle checks whether the server is
+// idle.
+const idleCheckInterval = time.Second
+
+// idle returns whether there is nothing to play, because the server is paused
+// or the queue is empty.
+func (s *Server) idle() bool {
+	s.pausedMu.RLock()
+	paused := s.paused
+	s.pausedMu.RUnlock()
+
+	s.queueMu.RLock()
+	empty := s.queue.Len() == 0
+	s.queueMu.RUnlock()
+
+	return bool(paused) || empty
+}
+
+// stopWhenIdle stops the server once it has been idle for the idle timeout,
+// until the server is closed.
+func (s *Server) stopWhenIdle() {
+	var idleSince time.Time
+	for {
+		select {
+		case <-time.After(idleCheckInterval):
+		case <-s.closed:
+			return
+		}
+
+		s.stoppedMu.Lock()
+		stopped := s.stopped
+		s.stoppedMu.Unlock()
+
+		// the time starts again once we resume
+		if bool(stopped) || !s.idle() {
+			idleSince = time.Time{}
+			continue
+		}
+
+		if idleSince.IsZero() {
+			idleSince = time.Now()
+		} else if time.Since(idleSince) >= s.idleTimeout {
+			s.stopIfIdle()
+		}
+	}
+}
+
+// stopIfIdle releases the output if the server is idle and isn't already
+// stopped. sink must not be locked.
+func (s *Server) stopIfIdle() {
+	s.stoppedMu.Lock()
+	// idle is checked while stopped is locked so that we can't stop after
+	// something that made us active has already tried to resume
+	if bool(s.stopped) || !s.idle() {
+		s.stoppedMu.Unlock()
+		return
+	}
+
+	if err := s.sink.Suspend(); err != nil {
+		s.stoppedMu.Unlock()
+		s.broadcastErr(fmt.Errorf("failed to suspend sink: %w", err))
+		return
+	}
+	s.stopped = true
+	s.stoppedMu.Unlock()
+
+	s.logger.Println("stopped")
+	s.broadcast(protocol.StopState(true))
+}
+
+// resumeIfActive reopens the output if the server is stopped and no longer
+// idle. sink must not be locked.
+func (s *Server) resumeIfActive() {
+	s.stoppedMu.Lock()
+	if !bool(s.stopped) || s.idle() {
+		s.stoppedMu.Unlock()
+		return
+	}
+
+	if err := s.sink.Resume(); err != nil {
+		s.stoppedMu.Unlock()
+		s.broadcastErr(fmt.Errorf("failed to resume sink: %w", err))
+		return
+	}
+	s.stopped = false
+	s.stoppedMu.Unlock()
+
+	s.logger.Println("resumed")
+	s.broadcast(protocol.StopState(false))
+}
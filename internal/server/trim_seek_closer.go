@@ -0,0 +1,66 @@
+package server
+
+import (
+	"github.com/faiface/beep"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// trimStreamSeekCloser wraps a beep.StreamSeekCloser, skipping its leading and
+// trailing silence once they are known.
+type trimStreamSeekCloser struct {
+	beep.StreamSeekCloser
+	// start is the position of the first sample after the leading silence,
+	// and end is the position where the trailing silence begins, or 0 if it
+	// isn't known. The sink must be locked to modify them.
+	start, end int
+	// started indicates whether anything has been streamed or seeked yet. The
+	// leading silence is only skipped before then, so that seeking back to
+	// the beginning still works.
+	started bool
+	err     error
+}
+
+func (t *trimStreamSeekCloser) Stream(samples [][2]float64) (n int, ok bool) {
+	if t.err != nil {
+		return 0, false
+	}
+
+	if !t.started {
+		t.started = true
+		if t.StreamSeekCloser.Position() < t.start {
+			if t.err = t.StreamSeekCloser.Seek(t.start); t.err != nil {
+				return 0, false
+			}
+		}
+	}
+
+	if t.end > 0 {
+		remaining := t.end - t.StreamSeekCloser.Position()
+		if remaining <= 0 {
+			return 0, false
+		}
+		samples = samples[:util.Min(len(samples), remaining)]
+	}
+
+	return t.StreamSeekCloser.Stream(samples)
+}
+
+func (t *trimStreamSeekCloser) Err() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.StreamSeekCloser.Err()
+}
+
+func (t *trimStreamSeekCloser) Len() int {
+	if t.end > 0 {
+		return t.end
+	}
+	return t.StreamSeekCloser.Len()
+}
+
+func (t *trimStreamSeekCloser) Seek(p int) error {
+	t.started = true
+	return t.StreamSeekCloser.Seek(p)
+}
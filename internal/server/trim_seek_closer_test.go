@@ -0,0 +1,70 @@
+package server
+
+import (
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+// newTrimTestStreamer returns a trimmed streamer of 10 samples whose values
+// are their positions, with 2 samples of leading silence and 3 of trailing
+// silence.
+func newTrimTestStreamer() *trimStreamSeekCloser {
+	samples := make([][2]float64, 10)
+	for i := range samples {
+		samples[i] = [2]float64{float64(i), float64(i)}
+	}
+
+	return &trimStreamSeekCloser{
+		StreamSeekCloser: &sliceStreamSeekCloser{samples: samples},
+		start:            2,
+		end:              7,
+	}
+}
+
+func TestTrimStreamSeekCloser(t *testing.T) {
+	s := newTrimTestStreamer()
+	assert.Equal(t, 7, s.Len())
+
+	// the leading silence is skipped
+	out := make([][2]float64, 3)
+	n, ok := s.Stream(out)
+	assert.Equal(t, 3, n)
+	assert.True(t, ok)
+	assert.Equal(t, [2]float64{2, 2}, out[0])
+	assert.Equal(t, 5, s.Position())
+
+	// the stream stops where the trailing silence begins
+	n, ok = s.Stream(out)
+	assert.Equal(t, 2, n)
+	assert.True(t, ok)
+	assert.Equal(t, [2]float64{6, 6}, out[1])
+	assert.Equal(t, 7, s.Position())
+
+	n, ok = s.Stream(out)
+	assert.Equal(t, 0, n)
+	assert.False(t, ok)
+	assert.Zero(t, s.Err())
+}
+
+func TestTrimStreamSeekCloserSeekStart(t *testing.T) {
+	s := newTrimTestStreamer()
+
+	// the leading silence isn't skipped after seeking back to the beginning
+	assert.Zero(t, s.Seek(0))
+	out := make([][2]float64, 1)
+	n, ok := s.Stream(out)
+	assert.Equal(t, 1, n)
+	assert.True(t, ok)
+	assert.Equal(t, [2]float64{0, 0}, out[0])
+}
+
+func TestTrimStreamSeekCloserSeekEnd(t *testing.T) {
+	s := newTrimTestStreamer()
+
+	assert.Zero(t, s.Seek(s.Len()))
+	n, ok := s.Stream(make([][2]float64, 1))
+	assert.Equal(t, 0, n)
+	assert.False(t, ok)
+	assert.Zero(t, s.Err())
+}
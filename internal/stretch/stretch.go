@@ -0,0 +1,206 @@
+// Package stretch changes the tempo of audio without changing its pitch,
+// using the waveform similarity overlap-add (WSOLA) algorithm, see
+// https://doi.org/10.1109/ICASSP.1993.319366.
+package stretch
+
+import (
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+
+	"mtoohey.com/q/internal/util"
+)
+
+const (
+	// frameDuration is the length of the frames that are overlapped and
+	// added.
+	frameDuration = 40 * time.Millisecond
+	// toleranceDuration is how far in either direction from its nominal
+	// position the start of each frame may be moved to find the position most
+	// similar to the natural continuation of the previous frame.
+	toleranceDuration = 10 * time.Millisecond
+	// searchStride is the stride used when searching for the most similar
+	// position and computing similarities, which trades accuracy for speed.
+	searchStride = 2
+)
+
+// Stretcher is a beep.Streamer that plays another streamer at a different
+// tempo, preserving its pitch.
+type Stretcher struct {
+	s     beep.Streamer
+	ratio float64
+	err   error
+
+	// frameLen is the length of each frame in samples, and hop is the
+	// distance between consecutive frames in the output, which is half of
+	// frameLen. tolerance is the maximum distance the start of a frame may be
+	// moved from its nominal position.
+	frameLen, hop, tolerance int
+	// window is a Hann window of frameLen samples. Consecutive windows
+	// overlapping by half sum to one.
+	window []float64
+
+	// in contains the input samples that may still be needed, the first of
+	// which is at inStart in the input. inEnd is the number of samples that
+	// have been read from s, and eof indicates whether s has ended.
+	in             [][2]float64
+	inStart, inEnd int
+	eof            bool
+	// pos is the nominal position of the next frame in the input, and prev is
+	// the actual position of the previous frame, or -1 if there isn't one.
+	pos  float64
+	prev int
+	// tail is the second half of the previous frame after it was windowed,
+	// which is added to the first half of the next frame.
+	tail [][2]float64
+	// out contains samples that have been produced but not yet streamed. It
+	// is a slice of buf, which is reused for each hop.
+	out, buf [][2]float64
+	// chunk is reused to read from s.
+	chunk [][2]float64
+}
+
+// New creates a Stretcher that plays s at ratio times its original tempo. s is
+// expected to be at sampleRate.
+func New(s beep.Streamer, sampleRate beep.SampleRate, ratio float64) *Stretcher {
+	frameLen := util.Max(sampleRate.N(frameDuration)/2*2, 4)
+	hop := frameLen / 2
+
+	window := make([]float64, frameLen)
+	for i := range window {
+		// periodic, so that overlapping windows sum to exactly one
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameLen))
+	}
+
+	return &Stretcher{
+		s:         s,
+		ratio:     ratio,
+		frameLen:  frameLen,
+		hop:       hop,
+		tolerance: sampleRate.N(toleranceDuration),
+		window:    window,
+		prev:      -1,
+		tail:      make([][2]float64, hop),
+		buf:       make([][2]float64, hop),
+		chunk:     make([][2]float64, 512),
+	}
+}
+
+// Stream streams the stretched samples, ending once the frames cover all of
+// the input.
+func (st *Stretcher) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if len(st.out) == 0 && !st.step() {
+			break
+		}
+
+		c := copy(samples[n:], st.out)
+		st.out = st.out[c:]
+		n += c
+	}
+
+	return n, n > 0
+}
+
+// Err returns the error of the underlying streamer, if it failed.
+func (st *Stretcher) Err() error {
+	return st.err
+}
+
+// Ratio returns the current tempo ratio.
+func (st *Stretcher) Ratio() float64 {
+	return st.ratio
+}
+
+// SetRatio changes the tempo ratio. Values greater than 1 speed up playback.
+func (st *Stretcher) SetRatio(ratio float64) {
+	st.ratio = ratio
+}
+
+// step appends the next hop of samples to st.out, returning false if the input
+// has been exhausted.
+func (st *Stretcher) step() bool {
+	nominal := int(math.Round(st.pos))
+	if !st.fill(nominal + st.tolerance + st.frameLen) {
+		return false
+	}
+
+	start := nominal
+	if st.prev >= 0 {
+		start = st.mostSimilar(nominal)
+	}
+
+	frame := st.in[start-st.inStart : start-st.inStart+st.frameLen]
+	for i := range st.buf {
+		w := st.window[i]
+		st.buf[i] = [2]float64{st.tail[i][0] + frame[i][0]*w, st.tail[i][1] + frame[i][1]*w}
+	}
+	for i := range st.tail {
+		w := st.window[st.hop+i]
+		st.tail[i] = [2]float64{frame[st.hop+i][0] * w, frame[st.hop+i][1] * w}
+	}
+	st.out = st.buf
+
+	st.prev = start
+	st.pos += float64(st.hop) * st.ratio
+
+	// discard the input that can no longer be needed, which is everything
+	// before both the natural continuation of this frame and the earliest
+	// possible start of the next one
+	keep := util.Min(st.prev+st.hop, int(math.Round(st.pos))-st.tolerance)
+	if drop := keep - st.inStart; drop > 0 {
+		st.in = st.in[util.Min(drop, len(st.in)):]
+		st.inStart += drop
+	}
+
+	return true
+}
+
+// mostSimilar returns the position within the tolerance of nominal whose
+// following samples are most similar to the natural continuation of the
+// previous frame, which is what would have followed it if the tempo were
+// unchanged.
+func (st *Stretcher) mostSimilar(nominal int) int {
+	continuation := st.in[st.prev+st.hop-st.inStart:]
+
+	best, bestSimilarity := nominal, math.Inf(-1)
+	for c := util.Max(nominal-st.tolerance, st.inStart); c <= nominal+st.tolerance; c += searchStride {
+		candidate := st.in[c-st.inStart:]
+
+		var similarity float64
+		for i := 0; i < st.hop; i += searchStride {
+			similarity += (candidate[i][0] + candidate[i][1]) * (continuation[i][0] + continuation[i][1])
+		}
+
+		if similarity > bestSimilarity {
+			best, bestSimilarity = c, similarity
+		}
+	}
+
+	return best
+}
+
+// fill reads from st.s until st.in extends to end in the input, padding it
+// with silence once st.s ends. It returns false if st.in only contains
+// padding from the start of the next frame onwards.
+func (st *Stretcher) fill(end int) bool {
+	for st.inStart+len(st.in) < end {
+		if st.eof {
+			st.in = append(st.in, [2]float64{})
+			continue
+		}
+
+		n, ok := st.s.Stream(st.chunk)
+		st.in = append(st.in, st.chunk[:n]...)
+		st.inEnd += n
+		if !ok {
+			st.eof = true
+			if errer, ok := st.s.(interface{ Err() error }); ok {
+				st.err = errer.Err()
+			}
+		}
+	}
+
+	return !st.eof || int(math.Round(st.pos)) < st.inEnd
+}
@@ -0,0 +1,65 @@
+package stretch
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+
+	"github.com/faiface/beep"
+)
+
+// sine returns a streamer of a sine wave with the given frequency and length.
+func sine(sampleRate beep.SampleRate, freq float64, length int) beep.Streamer {
+	i := 0
+	return beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+		for n < len(samples) && i < length {
+			v := math.Sin(2 * math.Pi * freq * float64(i) / float64(sampleRate))
+			samples[n] = [2]float64{v, v}
+			n++
+			i++
+		}
+		return n, n > 0
+	})
+}
+
+func TestStretcher(t *testing.T) {
+	const (
+		sampleRate = beep.SampleRate(44100)
+		freq       = 440
+		length     = 44100 * 2
+	)
+
+	for _, ratio := range []float64{0.5, 1, 1.5, 3} {
+		t.Run(strconv.FormatFloat(ratio, 'g', -1, 64), func(t *testing.T) {
+			st := New(sine(sampleRate, freq, length), sampleRate, ratio)
+
+			var out []float64
+			samples := make([][2]float64, 1000)
+			for {
+				n, ok := st.Stream(samples)
+				for _, s := range samples[:n] {
+					out = append(out, s[0])
+				}
+				if !ok {
+					break
+				}
+			}
+
+			expectedLen := float64(length) / ratio
+			assert.True(t, math.Abs(float64(len(out))-expectedLen) < expectedLen*0.05)
+
+			// ignore the fade in at the start and the end of the input
+			steady := out[sampleRate.N(frameDuration) : len(out)-sampleRate.N(frameDuration)]
+			crossings := 0
+			for i := 1; i < len(steady); i++ {
+				if (steady[i-1] < 0) != (steady[i] < 0) {
+					crossings++
+				}
+			}
+			actualFreq := float64(crossings) / 2 / (float64(len(steady)) / float64(sampleRate))
+			assert.True(t, math.Abs(actualFreq-freq) < freq*0.02)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// readAiffForm reads the header of an AIFF or AIFF-C file, see
+// https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/AIFF/Docs/AIFF-1.3.pdf
+// and https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/AIFF/Docs/AIFF-C.9.26.91.pdf,
+// and returns whether it's an AIFF-C file.
+func readAiffForm(r io.Reader) (bool, error) {
+	formType, err := readIffHeader(r, "FORM")
+	if err != nil {
+		return false, err
+	}
+
+	switch formType {
+	case "AIFF":
+		return false, nil
+	case "AIFC":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid form type %q", formType)
+	}
+}
+
+// readAiffID3 returns the contents of the ID3 chunk of an AIFF file. If there
+// is no ID3 chunk, the returned reader will be empty, which the id3 functions
+// treat as an empty tag.
+func readAiffID3(r io.Reader) (io.Reader, error) {
+	if _, err := readAiffForm(r); err != nil {
+		return nil, err
+	}
+
+	var id3 []byte
+	err := walkIffChunks(r, binary.BigEndian, func(c iffChunk) error {
+		// the chunk ID isn't standardized, so taggers use either case
+		if id3 != nil || c.id != "ID3 " && c.id != "id3 " {
+			return nil
+		}
+
+		var err error
+		if id3, err = io.ReadAll(c.body); err != nil {
+			return fmt.Errorf("%q chunk read failed: %w", c.id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(id3), nil
+}
+
+// parseExtended parses an 80 bit IEEE 754 extended precision float, which AIFF
+// uses for the sample rate.
+func parseExtended(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b) & 0x7fff)
+	mantissa := binary.BigEndian.Uint64(b[2:])
+
+	v := math.Ldexp(float64(mantissa), exponent-16383-63)
+	if b[0]&0x80 != 0 {
+		return -v
+	}
+	return v
+}
+
+// aiffIntSample decodes a big-endian signed integer sample of up to 32 bits.
+// Samples are left-justified, so the number of bits doesn't matter once the
+// bytes are in place.
+func aiffIntSample(b []byte) float64 {
+	var v int32
+	for i, c := range b {
+		v |= int32(c) << (24 - 8*i)
+	}
+	return float64(v) / (1 << 31)
+}
+
+// aiffSowtSample is like aiffIntSample, but for the little-endian samples of
+// the "sowt" AIFF-C compression type.
+func aiffSowtSample(b []byte) float64 {
+	var v int32
+	for i, c := range b {
+		v |= int32(c) << (32 - 8*len(b) + 8*i)
+	}
+	return float64(v) / (1 << 31)
+}
+
+func aiffFloat32Sample(b []byte) float64 {
+	return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+}
+
+func aiffFloat64Sample(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// aiffCommon contains the fields of the common chunk of an AIFF file that we
+// care about.
+type aiffCommon struct {
+	channels    int
+	frames      int64
+	sampleRate  beep.SampleRate
+	sampleBytes int
+	sample      func([]byte) float64
+}
+
+// parseAiffCommon parses the body of the common chunk of an AIFF file. The
+// compression type is only present in AIFF-C files.
+func parseAiffCommon(b []byte, compressed bool) (*aiffCommon, error) {
+	if len(b) < 18 || compressed && len(b) < 22 {
+		return nil, fmt.Errorf("chunk too short")
+	}
+
+	c := &aiffCommon{
+		channels: int(binary.BigEndian.Uint16(b)),
+		frames:   int64(binary.BigEndian.Uint32(b[2:])),
+	}
+	sampleSize := int(binary.BigEndian.Uint16(b[6:]))
+
+	sampleRate := parseExtended(b[8:18])
+	if sampleRate < 1 || sampleRate > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid sample rate %f", sampleRate)
+	}
+	c.sampleRate = beep.SampleRate(math.Round(sampleRate))
+
+	compression := "NONE"
+	if compressed {
+		compression = string(b[18:22])
+	}
+
+	switch compression {
+	case "NONE", "twos", "sowt":
+		if sampleSize < 1 || sampleSize > 32 {
+			return nil, fmt.Errorf("unsupported sample size %d", sampleSize)
+		}
+		c.sampleBytes = (sampleSize + 7) / 8
+		c.sample = aiffIntSample
+		if compression == "sowt" {
+			c.sample = aiffSowtSample
+		}
+	case "fl32", "FL32":
+		c.sampleBytes, c.sample = 4, aiffFloat32Sample
+	case "fl64", "FL64":
+		c.sampleBytes, c.sample = 8, aiffFloat64Sample
+	default:
+		return nil, fmt.Errorf("unsupported compression type %q", compression)
+	}
+
+	return c, nil
+}
+
+type aiffStream struct {
+	rsc io.ReadSeekCloser
+
+	channels, frameSize int
+	sample              func([]byte) float64
+
+	dataOffset int64
+	frames     int64
+
+	raw []byte
+	pos int64
+	err error
+}
+
+func decodeAiff(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	rsc, ok := rc.(io.ReadSeekCloser)
+	if !ok {
+		return nil, beep.Format{}, fmt.Errorf("reader is not seekable")
+	}
+
+	compressed, err := readAiffForm(rsc)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	var (
+		common               *aiffCommon
+		dataOffset, dataSize int64
+	)
+	err = walkIffChunks(rsc, binary.BigEndian, func(c iffChunk) error {
+		switch c.id {
+		case "COMM":
+			b, err := io.ReadAll(c.body)
+			if err != nil {
+				return fmt.Errorf("%q chunk read failed: %w", c.id, err)
+			}
+			if common, err = parseAiffCommon(b, compressed); err != nil {
+				return fmt.Errorf("%q chunk parse failed: %w", c.id, err)
+			}
+		case "SSND":
+			var offsets [8]byte
+			if _, err := io.ReadFull(c.body, offsets[:]); err != nil {
+				return fmt.Errorf("%q chunk read failed: %w", c.id, unexpectedEOF(err))
+			}
+			// the block size is only a hint for writing, so it's ignored
+			offset := int64(binary.BigEndian.Uint32(offsets[:4]))
+			dataOffset = c.offset + 8 + offset
+			dataSize = c.size - 8 - offset
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if common == nil {
+		return nil, beep.Format{}, fmt.Errorf("missing COMM chunk")
+	}
+
+	if common.channels != 1 && common.channels != 2 {
+		return nil, beep.Format{}, fmt.Errorf("unsupported channel count %d", common.channels)
+	}
+	frameSize := common.channels * common.sampleBytes
+
+	// files without sample frames don't need a sound data chunk, and the
+	// frame count is limited by the sound data chunk in case either is wrong
+	frames := util.Clamp(0, common.frames, dataSize/int64(frameSize))
+
+	if _, err := rsc.Seek(dataOffset, io.SeekStart); err != nil {
+		return nil, beep.Format{}, fmt.Errorf("seek failed: %w", err)
+	}
+
+	s := &aiffStream{
+		rsc:        rsc,
+		channels:   common.channels,
+		frameSize:  frameSize,
+		sample:     common.sample,
+		dataOffset: dataOffset,
+		frames:     frames,
+	}
+
+	return s, beep.Format{
+		SampleRate:  common.sampleRate,
+		NumChannels: common.channels,
+		Precision:   common.sampleBytes,
+	}, nil
+}
+
+func (s *aiffStream) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.err != nil {
+		return 0, false
+	}
+
+	frames := int(util.Min(int64(len(samples)), s.frames-s.pos))
+	if frames <= 0 {
+		return 0, false
+	}
+
+	if need := frames * s.frameSize; len(s.raw) < need {
+		s.raw = make([]byte, need)
+	}
+	read, err := io.ReadFull(s.rsc, s.raw[:frames*s.frameSize])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.err = fmt.Errorf("sample read failed: %w", err)
+		return 0, false
+	}
+
+	sampleBytes := s.frameSize / s.channels
+	for n = 0; n < read/s.frameSize; n++ {
+		frame := s.raw[n*s.frameSize:]
+		samples[n][0] = s.sample(frame[:sampleBytes])
+		samples[n][1] = samples[n][0]
+		if s.channels == 2 {
+			samples[n][1] = s.sample(frame[sampleBytes : 2*sampleBytes])
+		}
+	}
+
+	// a truncated file ends wherever its data does
+	if err != nil {
+		s.frames = s.pos + int64(n)
+	}
+	s.pos += int64(n)
+
+	return n, n > 0
+}
+
+func (s *aiffStream) Err() error {
+	return s.err
+}
+
+func (s *aiffStream) Len() int {
+	return int(s.frames)
+}
+
+func (s *aiffStream) Position() int {
+	return int(s.pos)
+}
+
+func (s *aiffStream) Seek(p int) error {
+	if p < 0 || int64(p) > s.frames {
+		return fmt.Errorf("seek position %d out of range [0, %d]", p, s.frames)
+	}
+
+	if _, err := s.rsc.Seek(s.dataOffset+int64(p*s.frameSize), io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+	s.pos = int64(p)
+	return nil
+}
+
+func (s *aiffStream) Close() error {
+	return s.rsc.Close()
+}
+
+var aiffFormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		id3, err := readAiffID3(r)
+		if err != nil {
+			return "", "", err
+		}
+
+		return id3Info(id3)
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		id3, err := readAiffID3(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3Cover(id3)
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		id3, err := readAiffID3(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3Lyrics(id3)
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		id3, err := readAiffID3(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3Metadata(id3)
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		id3, err := readAiffID3(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3ReplayGain(id3)
+	},
+	decode: decodeAiff,
+}
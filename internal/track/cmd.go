@@ -7,7 +7,7 @@ import (
 	"github.com/mattn/go-runewidth"
 )
 
-var header = [...]string{"format", "info", "cover", "lyrics", "metadata", "decode"}
+var header = [...]string{"format", "info", "cover", "lyrics", "metadata", "replaygain", "decode"}
 
 type Cmd struct{}
 
@@ -39,7 +39,8 @@ func (c *Cmd) Run() error {
 		row[2] = boolToCheckOrX(handler.cover != nil)
 		row[3] = boolToCheckOrX(handler.lyrics != nil)
 		row[4] = boolToCheckOrX(handler.metadata != nil)
-		row[5] = boolToCheckOrX(handler.decode != nil)
+		row[5] = boolToCheckOrX(handler.replayGain != nil)
+		row[6] = boolToCheckOrX(handler.decode != nil)
 
 		table[i+1] = row
 	}
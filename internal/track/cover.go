@@ -0,0 +1,124 @@
+package track
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultCoverNames contains the names of image files that are used as the
+// cover of tracks in the same directory that don't have one embedded by
+// default, in order of priority.
+var DefaultCoverNames = []string{"cover", "folder", "front", "album", "albumart"}
+
+// sidecarCoverExts contains the extensions of image formats that can be
+// decoded.
+var sidecarCoverExts = map[string]struct{}{".jpg": {}, ".jpeg": {}, ".png": {}}
+
+// maxSidecarCovers is the maximum number of directories whose sidecar covers
+// are cached.
+const maxSidecarCovers = 16
+
+// sidecarCoverKey identifies a cached sidecar cover.
+type sidecarCoverKey struct {
+	dir string
+	// names are the names that were searched for, separated by slashes, which
+	// can't appear in names.
+	names string
+}
+
+// sidecarCover is the cached sidecar cover of a directory.
+type sidecarCover struct {
+	once sync.Once
+	img  image.Image
+	err  error
+}
+
+var (
+	// sidecarCoversMu protects sidecarCovers and sidecarCoverKeys.
+	sidecarCoversMu sync.Mutex
+	// sidecarCovers caches sidecar covers by directory, so the tracks of an
+	// album share a single decoded image.
+	sidecarCovers = map[sidecarCoverKey]*sidecarCover{}
+	// sidecarCoverKeys contains the keys of sidecarCovers, from oldest to
+	// newest, so the oldest can be evicted.
+	sidecarCoverKeys []sidecarCoverKey
+)
+
+// readSidecarCover returns the sidecar cover of the given directory with one of
+// names, reading it if it isn't cached. It returns nil, nil if there is no
+// sidecar cover.
+func readSidecarCover(dir string, names []string) (image.Image, error) {
+	key := sidecarCoverKey{dir: dir, names: strings.Join(names, "/")}
+
+	sidecarCoversMu.Lock()
+	c, ok := sidecarCovers[key]
+	if !ok {
+		c = &sidecarCover{}
+		sidecarCovers[key] = c
+		sidecarCoverKeys = append(sidecarCoverKeys, key)
+
+		if len(sidecarCoverKeys) > maxSidecarCovers {
+			delete(sidecarCovers, sidecarCoverKeys[0])
+			sidecarCoverKeys = sidecarCoverKeys[1:]
+		}
+	}
+	sidecarCoversMu.Unlock()
+
+	c.once.Do(func() {
+		c.img, c.err = findSidecarCover(dir, names)
+	})
+
+	return c.img, c.err
+}
+
+// findSidecarCover searches the given directory for an image with one of
+// names, and decodes it. Names are matched case-insensitively, and may omit the
+// extension to match any supported image format.
+func findSidecarCover(dir string, names []string) (image.Image, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("directory read failed: %w", err)
+	}
+
+	// maps lower-case names, both with and without extensions, to the names of
+	// image files
+	images := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if _, ok := sidecarCoverExts[strings.ToLower(ext)]; !ok || entry.IsDir() {
+			continue
+		}
+
+		images[strings.ToLower(name)] = name
+		if base := strings.ToLower(strings.TrimSuffix(name, ext)); images[base] == "" {
+			images[base] = name
+		}
+	}
+
+	for _, coverName := range names {
+		name, ok := images[strings.ToLower(coverName)]
+		if !ok {
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("open failed: %w", err)
+		}
+		defer func() { _ = f.Close() }() // intentionally ignore close error
+
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s decode failed: %w", name, err)
+		}
+
+		return img, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,34 @@
+package track
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestReadSidecarCover(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"Cover.PNG": 2, "folder.png": 1} {
+		f, err := os.Create(filepath.Join(dir, name))
+		assert.Zero(t, err)
+		assert.Zero(t, png.Encode(f, image.NewGray(image.Rect(0, 0, size, size))))
+		assert.Zero(t, f.Close())
+	}
+
+	size := func(names []string) int {
+		img, err := readSidecarCover(dir, names)
+		assert.Zero(t, err)
+		if img == nil {
+			return 0
+		}
+		return img.Bounds().Dx()
+	}
+
+	assert.Equal(t, 2, size(DefaultCoverNames))
+	assert.Equal(t, 1, size([]string{"front", "folder.png", "cover"}))
+	assert.Equal(t, 0, size([]string{"front"}))
+}
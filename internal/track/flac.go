@@ -0,0 +1,115 @@
+package track
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/faiface/beep/flac"
+	"github.com/mewkiz/flac/meta"
+)
+
+// flacMetadata contains the parsed metadata blocks of a FLAC file that we care
+// about.
+type flacMetadata struct {
+	comments vorbisComments
+	pictures []*meta.Picture
+}
+
+// parseFlacMetadata reads the metadata blocks from the start of a FLAC file,
+// see https://www.xiph.org/flac/format.html#stream. Picture blocks are only
+// parsed if withPictures is true, since they can be large.
+func parseFlacMetadata(r io.Reader, withPictures bool) (flacMetadata, error) {
+	br := bufio.NewReader(r)
+
+	var signature [4]byte
+	if _, err := io.ReadFull(br, signature[:]); err != nil {
+		return flacMetadata{}, fmt.Errorf("signature read failed: %w", err)
+	}
+	if string(signature[:]) != "fLaC" {
+		return flacMetadata{}, fmt.Errorf("invalid signature % x", signature)
+	}
+
+	var m flacMetadata
+	for {
+		block, err := meta.New(br)
+		if err != nil {
+			return flacMetadata{}, fmt.Errorf("block header parse failed: %w", err)
+		}
+
+		switch {
+		case block.Type == meta.TypeVorbisComment,
+			block.Type == meta.TypePicture && withPictures:
+
+			if err := block.Parse(); err != nil {
+				return flacMetadata{}, fmt.Errorf("%s block parse failed: %w", block.Type, err)
+			}
+
+		default:
+			if err := block.Skip(); err != nil {
+				return flacMetadata{}, fmt.Errorf("%s block skip failed: %w", block.Type, err)
+			}
+		}
+
+		switch body := block.Body.(type) {
+		case *meta.VorbisComment:
+			m.comments = append(m.comments, body.Tags...)
+		case *meta.Picture:
+			m.pictures = append(m.pictures, body)
+		}
+
+		if block.IsLast {
+			return m, nil
+		}
+	}
+}
+
+var flacFormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		m, err := parseFlacMetadata(r, false)
+		if err != nil {
+			return "", "", fmt.Errorf("metadata parse failed: %w", err)
+		}
+
+		title, artist = m.comments.info()
+		return title, artist, nil
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		m, err := parseFlacMetadata(r, true)
+		if err != nil {
+			return nil, fmt.Errorf("metadata parse failed: %w", err)
+		}
+
+		return pictureCover(m.pictures)
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		m, err := parseFlacMetadata(r, false)
+		if err != nil {
+			return nil, fmt.Errorf("metadata parse failed: %w", err)
+		}
+
+		return m.comments.lyrics(), nil
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		m, err := parseFlacMetadata(r, false)
+		if err != nil {
+			return nil, fmt.Errorf("metadata parse failed: %w", err)
+		}
+
+		if m.comments == nil {
+			return nil, nil
+		}
+
+		return m.comments.metadata(), nil
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		m, err := parseFlacMetadata(r, false)
+		if err != nil {
+			return nil, fmt.Errorf("metadata parse failed: %w", err)
+		}
+
+		return m.comments.replayGain(), nil
+	},
+	decode: wrapReaderDecoder(flac.Decode),
+}
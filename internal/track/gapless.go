@@ -0,0 +1,92 @@
+package track
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/faiface/beep"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// gapless contains the number of samples that were added to the start and end
+// of a track by its encoder and decoder, which are trimmed so that consecutive
+// tracks play without gaps.
+type gapless struct {
+	// delay is the number of samples to trim from the start of the decoded
+	// stream.
+	delay int
+	// padding is the number of samples to trim from the end of the decoded
+	// stream.
+	padding int
+}
+
+// parseITunSMPB parses the iTunSMPB tag written by iTunes and other encoders,
+// which contains space-separated hexadecimal fields, the second and third of
+// which are the encoder delay and padding. It returns nil if the value is
+// invalid.
+func parseITunSMPB(value string) *gapless {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil
+	}
+
+	delay, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil {
+		return nil
+	}
+	padding, err := strconv.ParseUint(fields[2], 16, 32)
+	if err != nil {
+		return nil
+	}
+
+	return &gapless{delay: int(delay), padding: int(padding)}
+}
+
+// gaplessStreamSeekCloser wraps a beep.StreamSeekCloser, hiding the samples
+// that should be trimmed according to some gapless info.
+type gaplessStreamSeekCloser struct {
+	beep.StreamSeekCloser
+	delay, length int
+}
+
+// newGaplessStreamSeekCloser trims s according to g. s is returned unchanged
+// if g doesn't fit s.
+func newGaplessStreamSeekCloser(s beep.StreamSeekCloser, g gapless) (beep.StreamSeekCloser, error) {
+	length := s.Len() - g.delay - g.padding
+	if g.delay < 0 || g.padding < 0 || length <= 0 {
+		return s, nil
+	}
+
+	if err := s.Seek(g.delay); err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+
+	return &gaplessStreamSeekCloser{StreamSeekCloser: s, delay: g.delay, length: length}, nil
+}
+
+func (g *gaplessStreamSeekCloser) Stream(samples [][2]float64) (n int, ok bool) {
+	remaining := g.length - g.Position()
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	return g.StreamSeekCloser.Stream(samples[:util.Min(len(samples), remaining)])
+}
+
+func (g *gaplessStreamSeekCloser) Len() int {
+	return g.length
+}
+
+func (g *gaplessStreamSeekCloser) Position() int {
+	return g.StreamSeekCloser.Position() - g.delay
+}
+
+func (g *gaplessStreamSeekCloser) Seek(p int) error {
+	if p < 0 || p > g.length {
+		return fmt.Errorf("seek position %d out of range [0, %d]", p, g.length)
+	}
+
+	return g.StreamSeekCloser.Seek(p + g.delay)
+}
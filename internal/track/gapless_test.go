@@ -0,0 +1,92 @@
+package track
+
+import (
+	"bytes"
+	"testing"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+// lameFrame returns an MPEG-1 layer III frame containing a Xing header with
+// all optional fields and a LAME tag with the given delay and padding.
+func lameFrame(delay, padding int) []byte {
+	b := make([]byte, 417)
+	copy(b, []byte{0xFF, 0xFB, 0x90, 0x00})
+	copy(b[36:], "Info\x00\x00\x00\x0F")
+	copy(b[156:], "LAME3.100")
+	b[156+21] = byte(delay >> 4)
+	b[156+22] = byte(delay<<4 | padding>>8)
+	b[156+23] = byte(padding)
+	return b
+}
+
+func TestMp3Gapless(t *testing.T) {
+	t.Run("lame", func(t *testing.T) {
+		g, err := mp3Gapless(bytes.NewReader(lameFrame(576, 1000)))
+		assert.Zero(t, err)
+		assert.Equal(t, &gapless{delay: 1152 + 576 + 529, padding: 1000 - 529}, g)
+	})
+
+	t.Run("after tag", func(t *testing.T) {
+		tag := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 3, 0, 0, 0}
+		g, err := mp3Gapless(bytes.NewReader(append(tag, lameFrame(576, 100)...)))
+		assert.Zero(t, err)
+		assert.Equal(t, &gapless{delay: 1152 + 576 + 529}, g)
+	})
+
+	t.Run("no xing", func(t *testing.T) {
+		g, err := mp3Gapless(bytes.NewReader([]byte{0xFF, 0xFB, 0x90, 0x00, 0, 0, 0, 0, 0, 0}))
+		assert.Zero(t, err)
+		assert.Zero(t, g)
+	})
+}
+
+func TestParseITunSMPB(t *testing.T) {
+	assert.Equal(t, &gapless{delay: 2112, padding: 458}, parseITunSMPB(" 00000000 00000840 000001CA 00000000003F31F6 00000000 00000000"))
+	assert.Zero(t, parseITunSMPB("invalid"))
+}
+
+// sliceStreamSeekCloser streams a slice of samples.
+type sliceStreamSeekCloser struct {
+	samples [][2]float64
+	pos     int
+}
+
+func (s *sliceStreamSeekCloser) Stream(samples [][2]float64) (n int, ok bool) {
+	n = copy(samples, s.samples[s.pos:])
+	s.pos += n
+	return n, n > 0
+}
+
+func (s *sliceStreamSeekCloser) Err() error       { return nil }
+func (s *sliceStreamSeekCloser) Len() int         { return len(s.samples) }
+func (s *sliceStreamSeekCloser) Position() int    { return s.pos }
+func (s *sliceStreamSeekCloser) Seek(p int) error { s.pos = p; return nil }
+func (s *sliceStreamSeekCloser) Close() error     { return nil }
+
+func TestGaplessStreamSeekCloser(t *testing.T) {
+	samples := make([][2]float64, 10)
+	for i := range samples {
+		samples[i] = [2]float64{float64(i), float64(i)}
+	}
+
+	s, err := newGaplessStreamSeekCloser(&sliceStreamSeekCloser{samples: samples}, gapless{delay: 2, padding: 3})
+	assert.Zero(t, err)
+	assert.Equal(t, 5, s.Len())
+	assert.Equal(t, 0, s.Position())
+
+	out := make([][2]float64, 10)
+	n, ok := s.Stream(out)
+	assert.Equal(t, 5, n)
+	assert.True(t, ok)
+	assert.Equal(t, [2]float64{2, 2}, out[0])
+	assert.Equal(t, [2]float64{6, 6}, out[4])
+
+	_, ok = s.Stream(out)
+	assert.False(t, ok)
+
+	assert.Zero(t, s.Seek(4))
+	n, _ = s.Stream(out)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, [2]float64{6, 6}, out[0])
+}
@@ -0,0 +1,295 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/bogem/id3v2"
+)
+
+// id3Info reads the title and artist from an ID3v2 tag.
+func id3Info(r io.Reader) (title string, artist string, err error) {
+	tag, err := id3v2.ParseReader(r, id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"Title", "Artist"},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("tag parse failed: %w", err)
+	}
+
+	return tag.Title(), tag.Artist(), nil
+}
+
+// id3Cover reads the front cover picture from an ID3v2 tag, if it has one.
+func id3Cover(r io.Reader) (image.Image, error) {
+	tag, err := id3v2.ParseReader(r, id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"Attached picture"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tag parse failed: %w", err)
+	}
+
+	for _, f := range tag.GetFrames(tag.CommonID("Attached picture")) {
+		pf, ok := f.(id3v2.PictureFrame)
+		if !ok {
+			return nil, fmt.Errorf("picture assert failed")
+		}
+
+		// 3 is "Cover (front)", see https://id3.org/id3v2.3.0#Attached_picture
+		if pf.PictureType == 3 {
+			img, _, err := image.Decode(bytes.NewBuffer(pf.Picture))
+			if err != nil {
+				return nil, fmt.Errorf("cover decode failed: %w", err)
+			}
+
+			return img, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// id3Lyrics reads the lyrics from an ID3v2 tag, if it has any. Synchronised
+// lyrics take priority over unsynchronised ones.
+func id3Lyrics(r io.Reader) (*Lyrics, error) {
+	tag, err := id3v2.ParseReader(r, id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"Unsynchronised lyrics/text transcription", "SYLT"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tag parse failed: %w", err)
+	}
+
+	// TODO: can there be multiple lyrics frames? what would that mean? How
+	// should that be handled? Should we try to do something with the Language
+	// field to figure out what might be preferred, or should we let the user
+	// switch between languages if there are multiple?
+
+	// id3v2 doesn't know about SYLT frames, so we have to parse them
+	// ourselves
+	for _, f := range tag.GetFrames("SYLT") {
+		uf, ok := f.(id3v2.UnknownFrame)
+		if !ok {
+			return nil, fmt.Errorf("synchronised lyrics assert failed")
+		}
+
+		lyrics, err := parseSylt(uf.Body)
+		if err != nil {
+			return nil, fmt.Errorf("synchronised lyrics parse failed: %w", err)
+		}
+		if lyrics != nil {
+			return lyrics, nil
+		}
+	}
+
+	for _, f := range tag.GetFrames(tag.CommonID("Unsynchronised lyrics/text transcription")) {
+		ulf, ok := f.(id3v2.UnsynchronisedLyricsFrame)
+		if !ok {
+			return nil, fmt.Errorf("lyrics assert failed")
+		}
+
+		// despite the name of the frame, some taggers put LRC lyrics here
+		return parseLyrics(ulf.Lyrics), nil
+	}
+
+	return nil, nil
+}
+
+// parseSylt parses the body of a SYLT frame, see
+// https://id3.org/id3v2.3.0#Synchronised_lyrics.2Ftext. Only timestamps in
+// milliseconds are supported, so nil is returned for frames whose timestamps
+// are in MPEG frames.
+func parseSylt(b []byte) (*Lyrics, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("frame too short")
+	}
+	encoding, timestampFormat := b[0], b[4]
+	if timestampFormat != 2 {
+		return nil, nil
+	}
+
+	// skip the content descriptor
+	_, b = splitID3Text(b[6:], encoding)
+
+	type syllable struct {
+		offset time.Duration
+		text   string
+	}
+	var syllables []syllable
+	for len(b) > 0 {
+		var text string
+		text, b = splitID3Text(b, encoding)
+		if len(b) < 4 {
+			return nil, fmt.Errorf("missing timestamp")
+		}
+
+		syllables = append(syllables, syllable{
+			offset: time.Duration(binary.BigEndian.Uint32(b)) * time.Millisecond,
+			text:   text,
+		})
+		b = b[4:]
+	}
+
+	// the text can be split into syllables, in which case new lines start
+	// with a line break. Otherwise, each piece of text is its own line.
+	split := false
+	for _, s := range syllables {
+		if strings.HasPrefix(s.text, "\n") || strings.HasPrefix(s.text, "\r") {
+			split = true
+			break
+		}
+	}
+
+	var lines []LyricsLine
+	for i, s := range syllables {
+		text := strings.TrimLeft(s.text, "\r\n")
+		if split && i > 0 && text == s.text {
+			lines[len(lines)-1].Text += text
+			continue
+		}
+
+		lines = append(lines, LyricsLine{Offset: s.offset, Text: text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Offset < lines[j].Offset
+	})
+
+	return &Lyrics{Synced: true, Lines: lines}, nil
+}
+
+// splitID3Text splits the null-terminated text at the start of b from the rest
+// of b, and decodes it according to the given ID3v2 text encoding.
+func splitID3Text(b []byte, encoding byte) (string, []byte) {
+	// UTF-16 text is terminated by two null bytes, which have to be aligned
+	width := 1
+	if encoding == 1 || encoding == 2 {
+		width = 2
+	}
+
+	end, rest := len(b), len(b)
+	for i := 0; i+width <= len(b); i += width {
+		if b[i] == 0 && b[i+width-1] == 0 {
+			end, rest = i, i+width
+			break
+		}
+	}
+
+	return decodeID3Text(b[:end], encoding), b[rest:]
+}
+
+// decodeID3Text decodes text according to the given ID3v2 text encoding.
+func decodeID3Text(b []byte, encoding byte) string {
+	switch encoding {
+	case 0:
+		// ISO-8859-1 maps directly to the first 256 code points
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+
+	case 1, 2:
+		// UTF-16 with a byte order mark, or big-endian UTF-16 without one
+		var order binary.ByteOrder = binary.BigEndian
+		if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+			order, b = binary.LittleEndian, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+			b = b[2:]
+		}
+
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units))
+
+	default:
+		return string(b)
+	}
+}
+
+// id3ReplayGain reads the ReplayGain values from the TXXX frames of an ID3v2
+// tag, if it has any.
+func id3ReplayGain(r io.Reader) (*ReplayGain, error) {
+	tag, err := id3v2.ParseReader(r, id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"User defined text information frame"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tag parse failed: %w", err)
+	}
+
+	// descriptions are matched case-insensitively, since taggers disagree on
+	// their case
+	values := map[string]string{}
+	for _, f := range tag.GetFrames(tag.CommonID("User defined text information frame")) {
+		udtf, ok := f.(id3v2.UserDefinedTextFrame)
+		if !ok {
+			return nil, fmt.Errorf("user defined text assert failed")
+		}
+
+		values[strings.ToUpper(udtf.Description)] = udtf.Value
+	}
+
+	return parseReplayGain(func(name string) string {
+		return values[name]
+	}), nil
+}
+
+// id3Metadata reads all text frames from an ID3v2 tag, keyed by the shortest
+// friendly name for their ID.
+func id3Metadata(r io.Reader) (map[string]string, error) {
+	tag, err := id3v2.ParseReader(r, id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, fmt.Errorf("tag parse failed: %w", err)
+	}
+
+	m := map[string]string{}
+
+	var ids map[string]string
+	if tag.Version() == 3 {
+		ids = id3v2.V23CommonIDs
+	} else {
+		ids = id3v2.V24CommonIDs
+	}
+
+	// Returns the shortest friendly name for an ID
+	getIDName := func(id string) string {
+		var shortestName string
+		for name, oID := range ids {
+			if id == oID && (shortestName == "" || len(shortestName) > len(name)) {
+				shortestName = name
+			}
+		}
+
+		// If we didn't find a friendly name, return the original ID
+		// instead of returning nothing
+		if shortestName == "" {
+			return id
+		}
+
+		return shortestName
+	}
+
+	for id := range tag.AllFrames() {
+		if textFrame, ok := tag.GetLastFrame(id).(id3v2.TextFrame); ok {
+			m[getIDName(id)] = textFrame.Text
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package track
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// iffChunk is a chunk within an IFF-style file, such as AIFF or RIFF WAVE.
+type iffChunk struct {
+	id string
+	// offset is the position of the start of the chunk's body within the
+	// file.
+	offset int64
+	// size is the size of the chunk's body, as declared in its header.
+	size int64
+	body *io.LimitedReader
+}
+
+// readIffHeader reads the 12 byte header at the start of an IFF-style file,
+// checks that it has the given group ID, and returns its form type.
+func readIffHeader(r io.Reader, groupID string) (string, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", fmt.Errorf("header read failed: %w", err)
+	}
+	if string(header[:4]) != groupID {
+		return "", fmt.Errorf("invalid group id % x", header[:4])
+	}
+
+	// the size in the header is ignored, since it's often wrong for files
+	// that were written as a stream
+	return string(header[8:]), nil
+}
+
+// walkIffChunks calls fn with each of the chunks following the header of an
+// IFF-style file, where order is the byte order of the chunk sizes. Whatever
+// fn doesn't read of a chunk's body is skipped.
+func walkIffChunks(r io.Reader, order binary.ByteOrder, fn func(c iffChunk) error) error {
+	offset := int64(12)
+	for {
+		var header [8]byte
+		_, err := io.ReadFull(r, header[:])
+		// a partial header can only be trailing junk, since there's no room
+		// for a body after it
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("chunk header read failed: %w", err)
+		}
+
+		c := iffChunk{
+			id:     string(header[:4]),
+			offset: offset + 8,
+			size:   int64(order.Uint32(header[4:])),
+		}
+		c.body = &io.LimitedReader{R: r, N: c.size}
+		if err := fn(c); err != nil {
+			return err
+		}
+
+		// chunks are padded to an even size. The last chunk of a truncated
+		// file might end early, which is treated like the end of the file.
+		err = skip(r, c.body.N+c.size&1)
+		if err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%q chunk skip failed: %w", c.id, err)
+		}
+		offset = c.offset + c.size + c.size&1
+	}
+}
@@ -0,0 +1,141 @@
+package track
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// Lyrics contains the lyrics of a track.
+type Lyrics struct {
+	// Synced indicates whether the lines have offsets. If it is false, the
+	// offsets of all lines are zero.
+	Synced bool
+
+	// Lines contains the lines of the lyrics, sorted by offset if they are
+	// synced.
+	Lines []LyricsLine
+}
+
+// LyricsLine is a single line of lyrics.
+type LyricsLine struct {
+	// Offset is the position in the track at which this line begins.
+	Offset time.Duration
+
+	// Text is the text of the line.
+	Text string
+}
+
+var (
+	// lrcTimeTag matches a time tag at the start of a line of LRC lyrics, such
+	// as [01:23.45].
+	lrcTimeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2}(?:[.:]\d+)?)\]`)
+	// lrcIDTag matches an ID tag, such as [ar:Artist] or [offset:+500].
+	lrcIDTag = regexp.MustCompile(`^\[([a-z]+):(.*)\]$`)
+	// lrcWordTimeTag matches the per-word time tags of enhanced LRC lyrics,
+	// such as <01:23.45>, which are removed, since only lines are synced.
+	lrcWordTimeTag = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d+)?>\s?`)
+)
+
+// parseLrcTime parses the minutes and seconds of an LRC time tag. Some writers
+// separate the hundredths of a second with a colon, so that's allowed too.
+func parseLrcTime(minutes, seconds string) time.Duration {
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.ParseFloat(strings.Replace(seconds, ":", ".", 1), 64)
+	return time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+}
+
+// parseLyrics parses lyrics text, which may be in the LRC format (see
+// https://en.wikipedia.org/wiki/LRC_(file_format)), in which case the returned
+// lyrics are synced. Otherwise, each line of the text becomes an unsynced
+// line. If text is empty, nil is returned.
+func parseLyrics(text string) *Lyrics {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+
+	var (
+		lines  []LyricsLine
+		offset time.Duration
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		var offsets []time.Duration
+		for {
+			m := lrcTimeTag.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+
+			offsets = append(offsets, parseLrcTime(m[1], m[2]))
+			line = line[len(m[0]):]
+		}
+
+		if len(offsets) == 0 {
+			if m := lrcIDTag.FindStringSubmatch(line); m != nil && m[1] == "offset" {
+				// positive offsets make lines appear sooner
+				ms, _ := strconv.Atoi(strings.TrimSpace(m[2]))
+				offset = time.Duration(ms) * time.Millisecond
+			}
+			continue
+		}
+
+		line = strings.TrimSpace(lrcWordTimeTag.ReplaceAllString(line, ""))
+		for _, o := range offsets {
+			lines = append(lines, LyricsLine{Offset: o, Text: line})
+		}
+	}
+
+	if len(lines) == 0 {
+		return plainLyrics(text)
+	}
+
+	for i := range lines {
+		lines[i].Offset = util.Max(lines[i].Offset-offset, 0)
+	}
+	// a line can have several time tags, such as a repeated chorus
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Offset < lines[j].Offset
+	})
+
+	return &Lyrics{Synced: true, Lines: lines}
+}
+
+// plainLyrics returns the unsynced lyrics for the given text, which should not
+// be empty.
+func plainLyrics(text string) *Lyrics {
+	var lines []LyricsLine
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, LyricsLine{Text: strings.TrimRight(line, " \t")})
+	}
+
+	return &Lyrics{Lines: lines}
+}
+
+// sidecarLyricsPath returns the path of the .lrc file that may accompany the
+// audio file at the given path.
+func sidecarLyricsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc"
+}
+
+// readSidecarLyrics reads and parses the .lrc file next to the audio file at
+// the given path. It returns nil, nil if there is no such file.
+func readSidecarLyrics(path string) (*Lyrics, error) {
+	b, err := os.ReadFile(sidecarLyricsPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLyrics(string(b)), nil
+}
@@ -0,0 +1,91 @@
+package track
+
+import (
+	"testing"
+	"time"
+
+	"mtoohey.com/q/internal/testutil/assert"
+)
+
+func TestParseLyrics(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assert.Zero(t, parseLyrics(" \r\n"))
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		assert.Equal(t, &Lyrics{Lines: []LyricsLine{
+			{Text: "first line"},
+			{Text: ""},
+			{Text: "[not a time] tag"},
+		}}, parseLyrics("first line\r\n\r\n[not a time] tag\n"))
+	})
+
+	t.Run("lrc", func(t *testing.T) {
+		assert.Equal(t, &Lyrics{Synced: true, Lines: []LyricsLine{
+			{Offset: 1500 * time.Millisecond, Text: "first"},
+			{Offset: 3*time.Second + 250*time.Millisecond, Text: "chorus"},
+			{Offset: 62 * time.Second, Text: ""},
+			{Offset: 65 * time.Second, Text: "chorus"},
+		}}, parseLyrics(`[ar:Artist]
+[ti:Title]
+[00:01.50]first
+[00:03.25][01:05.00]chorus
+[01:02]
+`))
+	})
+
+	t.Run("offset", func(t *testing.T) {
+		assert.Equal(t, &Lyrics{Synced: true, Lines: []LyricsLine{
+			{Offset: 0, Text: "clamped"},
+			{Offset: 500 * time.Millisecond, Text: "earlier"},
+		}}, parseLyrics("[offset:+1000]\n[00:00.50]clamped\n[00:01.50]earlier"))
+	})
+
+	t.Run("enhanced", func(t *testing.T) {
+		assert.Equal(t, &Lyrics{Synced: true, Lines: []LyricsLine{
+			{Offset: 10 * time.Second, Text: "word by word"},
+		}}, parseLyrics("[00:10.00] <00:10.00> word <00:10.50> by <00:11.00> word"))
+	})
+}
+
+func TestParseSylt(t *testing.T) {
+	header := []byte{3, 'e', 'n', 'g', 2, 1, 'd', 'e', 's', 'c', 0}
+
+	t.Run("lines", func(t *testing.T) {
+		body := append(append([]byte{}, header...),
+			'b', 0, 0, 0, 0x07, 0xd0,
+			'a', 0, 0, 0, 0x03, 0xe8,
+		)
+
+		lyrics, err := parseSylt(body)
+		assert.Zero(t, err)
+		assert.Equal(t, &Lyrics{Synced: true, Lines: []LyricsLine{
+			{Offset: time.Second, Text: "a"},
+			{Offset: 2 * time.Second, Text: "b"},
+		}}, lyrics)
+	})
+
+	t.Run("syllables", func(t *testing.T) {
+		body := append(append([]byte{}, header...),
+			'o', 'n', 0, 0, 0, 0, 0,
+			'e', 0, 0, 0, 0, 0x64,
+			'\n', 't', 'w', 'o', 0, 0, 0, 0x03, 0xe8,
+		)
+
+		lyrics, err := parseSylt(body)
+		assert.Zero(t, err)
+		assert.Equal(t, &Lyrics{Synced: true, Lines: []LyricsLine{
+			{Offset: 0, Text: "one"},
+			{Offset: time.Second, Text: "two"},
+		}}, lyrics)
+	})
+
+	t.Run("mpeg frames", func(t *testing.T) {
+		body := append([]byte{}, header...)
+		body[4] = 1
+
+		lyrics, err := parseSylt(body)
+		assert.Zero(t, err)
+		assert.Zero(t, lyrics)
+	})
+}
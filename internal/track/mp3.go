@@ -2,121 +2,206 @@ package track
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
-	"image"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
+	"strings"
 
 	"github.com/bogem/id3v2"
 	"github.com/faiface/beep/mp3"
-)
-
-var mp3FormatHandler = &formatHandler{
-	info: func(r io.Reader) (title string, artist string, err error) {
-		tag, err := id3v2.ParseReader(r, id3v2.Options{
-			Parse:       true,
-			ParseFrames: []string{"Title", "Artist"},
-		})
-		if err != nil {
-			return "", "", fmt.Errorf("tag parse failed: %w", err)
-		}
 
-		return tag.Title(), tag.Artist(), nil
-	},
-	cover: func(r io.Reader) (image.Image, error) {
-		tag, err := id3v2.ParseReader(r, id3v2.Options{
-			Parse:       true,
-			ParseFrames: []string{"Attached picture"},
-		})
-		if err != nil {
-			return nil, fmt.Errorf("tag parse failed: %w", err)
-		}
+	"mtoohey.com/q/internal/util"
+)
 
-		for _, f := range tag.GetFrames(tag.CommonID("Attached picture")) {
-			pf, ok := f.(id3v2.PictureFrame)
-			if !ok {
-				return nil, fmt.Errorf("picture assert failed")
-			}
+const (
+	// mp3DecoderDelay is the number of samples by which the output of an MP3
+	// decoder lags its input, due to the filterbanks.
+	mp3DecoderDelay = 529
+	// mp3MaxFrameSearch is the number of bytes after the ID3v2 tag that are
+	// searched for the first frame.
+	mp3MaxFrameSearch = 1 << 16
+)
 
-			// 3 is "Cover (front)", see https://id3.org/id3v2.3.0#Attached_picture
-			if pf.PictureType == 3 {
-				img, _, err := image.Decode(bytes.NewBuffer(pf.Picture))
-				if err != nil {
-					return nil, fmt.Errorf("cover decode failed: %w", err)
-				}
+// mp3FrameHeader contains the fields of an MPEG audio frame header that are
+// needed to find the Xing header, see
+// http://www.mp3-tech.org/programmer/frame_header.html.
+type mp3FrameHeader struct {
+	mpeg1, mono, crc bool
+}
 
-				return img, nil
-			}
-		}
+// parseMp3FrameHeader parses a layer III frame header, returning false if b
+// doesn't start with one.
+func parseMp3FrameHeader(b []byte) (mp3FrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3FrameHeader{}, false
+	}
+
+	version := b[1] >> 3 & 0b11
+	layer := b[1] >> 1 & 0b11
+	bitrate := b[2] >> 4
+	sampleRate := b[2] >> 2 & 0b11
+	if version == 0b01 || layer != 0b01 || bitrate == 0b1111 || sampleRate == 0b11 {
+		return mp3FrameHeader{}, false
+	}
+
+	return mp3FrameHeader{
+		mpeg1: version == 0b11,
+		mono:  b[3]>>6 == 0b11,
+		crc:   b[1]&1 == 0,
+	}, true
+}
 
-		return nil, nil
-	},
-	lyrics: func(r io.Reader) (string, error) {
-		tag, err := id3v2.ParseReader(r, id3v2.Options{
-			Parse:       true,
-			ParseFrames: []string{"Unsynchronised lyrics/text transcription"},
-		})
-		if err != nil {
-			return "", fmt.Errorf("tag parse failed: %w", err)
-		}
+// samplesPerFrame returns the number of samples decoded from each frame.
+func (h mp3FrameHeader) samplesPerFrame() int {
+	if h.mpeg1 {
+		return 1152
+	}
+	return 576
+}
 
-		for _, f := range tag.GetFrames(tag.CommonID("Unsynchronised lyrics/text transcription")) {
-			ulf, ok := f.(id3v2.UnsynchronisedLyricsFrame)
-			if !ok {
-				return "", fmt.Errorf("lyrics assert failed")
-			}
+// xingOffset returns the offset of the Xing header from the start of the
+// frame, which follows the side information.
+func (h mp3FrameHeader) xingOffset() int {
+	offset := 4
+	if h.crc {
+		offset += 2
+	}
+
+	switch {
+	case h.mpeg1 && h.mono:
+		return offset + 17
+	case h.mpeg1:
+		return offset + 32
+	case h.mono:
+		return offset + 9
+	default:
+		return offset + 17
+	}
+}
 
-			// TODO: can there be multiple lyrics frames? what would that mean?
-			// How should that be handled? Should we try to do something with
-			// the Language field to figure out what might be preferred, or
-			// should we let the user switch between languages if there are
-			// multiple?
-			return ulf.Lyrics, nil
+// mp3Gapless reads the gapless info of an MP3 file from the LAME tag of the
+// Xing header in its first frame, see
+// http://gabriel.mp3-tech.org/mp3infotag.html, or from an iTunSMPB comment if
+// there's no LAME tag. It returns nil if neither can be found.
+func mp3Gapless(r io.Reader) (*gapless, error) {
+	var header [10]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("header read failed: %w", unexpectedEOF(err))
+	}
+
+	var tag []byte
+	b := header[:]
+	if string(header[:3]) == "ID3" {
+		size := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
+		if header[5]&0x10 != 0 {
+			// footer present
+			size += 10
 		}
 
-		return "", nil
-	},
-	metadata: func(r io.Reader) (map[string]string, error) {
-		tag, err := id3v2.ParseReader(r, id3v2.Options{Parse: true})
-		if err != nil {
-			return nil, fmt.Errorf("tag parse failed: %w", err)
+		tag = make([]byte, 10+size)
+		copy(tag, header[:])
+		if _, err := io.ReadFull(r, tag[10:]); err != nil {
+			return nil, fmt.Errorf("tag read failed: %w", unexpectedEOF(err))
 		}
-
-		m := map[string]string{}
-
-		var ids map[string]string
-		if tag.Version() == 3 {
-			ids = id3v2.V23CommonIDs
-		} else {
-			ids = id3v2.V24CommonIDs
+		b = nil
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(r, mp3MaxFrameSearch))
+	if err != nil {
+		return nil, fmt.Errorf("frame read failed: %w", err)
+	}
+	b = append(b, rest...)
+
+	// encoders may leave some junk between the tag and the first frame
+	var (
+		h     mp3FrameHeader
+		found bool
+	)
+	for i := range b {
+		if h, found = parseMp3FrameHeader(b[i:]); found {
+			b = b[i:]
+			break
 		}
-
-		// Returns the shortest friendly name for an ID
-		getIDName := func(id string) string {
-			var shortestName string
-			for name, oID := range ids {
-				if id == oID && (shortestName == "" || len(shortestName) > len(name)) {
-					shortestName = name
+	}
+
+	var xing bool
+	if offset := h.xingOffset(); found && len(b) >= offset+8 {
+		if id := string(b[offset : offset+4]); id == "Xing" || id == "Info" {
+			xing = true
+
+			flags := binary.BigEndian.Uint32(b[offset+4:])
+			lame := offset + 8
+			for _, field := range []struct {
+				flag uint32
+				size int
+			}{{1, 4}, {2, 4}, {4, 100}, {8, 4}} {
+				if flags&field.flag != 0 {
+					lame += field.size
 				}
 			}
 
-			// If we didn't find a friendly name, return the original ID
-			// instead of returning nothing
-			if shortestName == "" {
-				return id
+			if len(b) >= lame+24 {
+				switch string(b[lame : lame+4]) {
+				case "LAME", "Lavc":
+					// two 12-bit fields
+					delay := int(b[lame+21])<<4 | int(b[lame+22])>>4
+					padding := int(b[lame+22]&0x0F)<<8 | int(b[lame+23])
+
+					// the frame containing the Xing header decodes to
+					// silence, so it's skipped too
+					return &gapless{
+						delay:   h.samplesPerFrame() + delay + mp3DecoderDelay,
+						padding: util.Max(padding-mp3DecoderDelay, 0),
+					}, nil
+				}
 			}
-
-			return shortestName
 		}
+	}
+
+	var g *gapless
+	if tag != nil {
+		g = id3ITunSMPB(tag)
+	}
+	if xing {
+		if g == nil {
+			g = &gapless{}
+		}
+		g.delay += h.samplesPerFrame()
+	}
+	return g, nil
+}
 
-		for id := range tag.AllFrames() {
-			if textFrame, ok := tag.GetLastFrame(id).(id3v2.TextFrame); ok {
-				m[getIDName(id)] = textFrame.Text
-			}
+// id3ITunSMPB reads the iTunSMPB comment from an ID3v2 tag. It returns nil if
+// there isn't a valid one, or if the tag can't be parsed.
+func id3ITunSMPB(b []byte) *gapless {
+	tag, err := id3v2.ParseReader(bytes.NewReader(b), id3v2.Options{
+		Parse:       true,
+		ParseFrames: []string{"Comments", "User defined text information frame"},
+	})
+	if err != nil {
+		return nil
+	}
+
+	for _, f := range tag.GetFrames(tag.CommonID("Comments")) {
+		if cf, ok := f.(id3v2.CommentFrame); ok && strings.EqualFold(cf.Description, "iTunSMPB") {
+			return parseITunSMPB(cf.Text)
+		}
+	}
+	for _, f := range tag.GetFrames(tag.CommonID("User defined text information frame")) {
+		if udtf, ok := f.(id3v2.UserDefinedTextFrame); ok && strings.EqualFold(udtf.Description, "iTunSMPB") {
+			return parseITunSMPB(udtf.Value)
 		}
+	}
 
-		return m, nil
-	},
-	decode: mp3.Decode,
+	return nil
+}
+
+var mp3FormatHandler = &formatHandler{
+	info:       id3Info,
+	cover:      id3Cover,
+	lyrics:     id3Lyrics,
+	metadata:   id3Metadata,
+	replayGain: id3ReplayGain,
+	gapless:    mp3Gapless,
+	decode:     mp3.Decode,
 }
@@ -0,0 +1,502 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// mp4Brands contains the major brands of ftyp boxes that indicate files we
+// should try to handle as MP4 audio, see https://mp4ra.org/#/brands.
+var mp4Brands = map[string]struct{}{
+	"isom": {}, "iso2": {}, "iso3": {}, "iso4": {}, "iso5": {}, "iso6": {},
+	"mp41": {}, "mp42": {}, "M4A ": {}, "M4B ": {}, "M4P ": {}, "M4V ": {},
+	"3gp4": {}, "3gp5": {}, "3gp6": {}, "3g2a": {}, "dash": {}, "f4a ": {},
+	"f4b ": {}, "F4A ": {}, "F4B ": {}, "mqt ": {}, "qt  ": {},
+}
+
+// isMp4 reports whether magic, the first 12 bytes of a file, is the start of
+// an ftyp box with a supported brand.
+func isMp4(magic []byte) bool {
+	if string(magic[4:8]) != "ftyp" {
+		return false
+	}
+
+	_, ok := mp4Brands[string(magic[8:12])]
+	return ok
+}
+
+// mp4Box is a single box (also known as an atom) from an MP4 file, see ISO/IEC
+// 14496-12.
+type mp4Box struct {
+	boxType string
+	body    []byte
+}
+
+// readMp4BoxHeader reads the header of the box at the current position of r.
+// It returns a bodyLen of -1 if the box extends to the end of the file.
+func readMp4BoxHeader(r io.Reader) (boxType string, bodyLen int64, err error) {
+	var raw [8]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return "", 0, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(raw[:4]))
+	boxType = string(raw[4:])
+
+	switch size {
+	case 0:
+		return boxType, -1, nil
+
+	case 1:
+		var large [8]byte
+		if _, err := io.ReadFull(r, large[:]); err != nil {
+			return "", 0, fmt.Errorf("large size read failed: %w", unexpectedEOF(err))
+		}
+
+		size = int64(binary.BigEndian.Uint64(large[:]))
+		if size < 16 {
+			return "", 0, fmt.Errorf("invalid large size %d for %q box", size, boxType)
+		}
+		return boxType, size - 16, nil
+
+	default:
+		if size < 8 {
+			return "", 0, fmt.Errorf("invalid size %d for %q box", size, boxType)
+		}
+		return boxType, size - 8, nil
+	}
+}
+
+// maxMp4MoovLen is the maximum length of the body of a moov box that we will
+// read, so that corrupt sizes can't make us allocate an unbounded amount of
+// memory. Even hours long files have moov boxes of only a few megabytes.
+const maxMp4MoovLen = 64 << 20
+
+// readMp4Moov reads the body of the top-level moov box, which contains all of
+// the file's metadata. Other top-level boxes are skipped, so that we don't
+// have to read through the media data.
+func readMp4Moov(r io.Reader) ([]byte, error) {
+	for {
+		boxType, bodyLen, err := readMp4BoxHeader(r)
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("no moov box")
+			}
+			return nil, fmt.Errorf("box header read failed: %w", err)
+		}
+
+		if boxType == "moov" {
+			if bodyLen < 0 {
+				body, err := io.ReadAll(io.LimitReader(r, maxMp4MoovLen+1))
+				if err != nil {
+					return nil, fmt.Errorf("moov read failed: %w", err)
+				}
+				if len(body) > maxMp4MoovLen {
+					return nil, fmt.Errorf("moov box is too large")
+				}
+				return body, nil
+			}
+
+			if bodyLen > maxMp4MoovLen {
+				return nil, fmt.Errorf("moov box length %d is too large", bodyLen)
+			}
+			if remaining, ok := remainingLen(r); ok && bodyLen > remaining {
+				return nil, fmt.Errorf("moov box length %d overflows the file", bodyLen)
+			}
+
+			body := make([]byte, bodyLen)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("moov read failed: %w", unexpectedEOF(err))
+			}
+			return body, nil
+		}
+
+		if bodyLen < 0 {
+			return nil, fmt.Errorf("no moov box")
+		}
+		if err := skip(r, bodyLen); err != nil {
+			return nil, fmt.Errorf("%q box skip failed: %w", boxType, err)
+		}
+	}
+}
+
+// remainingLen returns the number of bytes after the current position of r,
+// if r is an io.Seeker.
+func remainingLen(r io.Reader) (int64, bool) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+
+	cur, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+	if _, err := seeker.Seek(cur, io.SeekStart); err != nil {
+		return 0, false
+	}
+
+	return end - cur, true
+}
+
+// parseMp4Boxes splits b, which must contain a sequence of complete boxes,
+// into its boxes.
+func parseMp4Boxes(b []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for len(b) > 0 {
+		r := bytes.NewReader(b)
+		boxType, bodyLen, err := readMp4BoxHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("box header read failed: %w", unexpectedEOF(err))
+		}
+
+		headerLen := int64(len(b)) - int64(r.Len())
+		if bodyLen < 0 {
+			bodyLen = int64(r.Len())
+		} else if bodyLen > int64(r.Len()) {
+			return nil, fmt.Errorf("%q box overflows its parent", boxType)
+		}
+
+		boxes = append(boxes, mp4Box{
+			boxType: boxType,
+			body:    b[headerLen : headerLen+bodyLen],
+		})
+		b = b[headerLen+bodyLen:]
+	}
+
+	return boxes, nil
+}
+
+// findMp4Box descends through the boxes in b, following path, and returns the
+// body of the box at the end of the path, or nil if there is no such box.
+func findMp4Box(b []byte, path ...string) ([]byte, error) {
+	for _, boxType := range path {
+		boxes, err := parseMp4Boxes(b)
+		if err != nil {
+			return nil, err
+		}
+
+		b = nil
+		for _, box := range boxes {
+			if box.boxType == boxType {
+				b = box.body
+				break
+			}
+		}
+		if b == nil {
+			return nil, nil
+		}
+
+		if boxType == "meta" {
+			// meta is a full box with 4 bytes of version and flags before its
+			// children in ISO files, but not in QuickTime files, which we can
+			// recognize by the hdlr box starting immediately
+			if len(b) >= 8 && string(b[4:8]) != "hdlr" {
+				b = b[4:]
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// Well-known types for data boxes, see
+// https://developer.apple.com/documentation/quicktime-file-format/well-known_types
+const (
+	mp4DataTypeImplicit = 0
+	mp4DataTypeUTF8     = 1
+	mp4DataTypeUTF16    = 2
+	mp4DataTypeJPEG     = 13
+	mp4DataTypePNG      = 14
+	mp4DataTypeBEInt    = 21
+)
+
+// mp4Item is a single value from an ilst box.
+type mp4Item struct {
+	// key is the type of the item's box, such as "©nam". For freeform items,
+	// it has the form "----:<mean>:<name>", such as
+	// "----:com.apple.iTunes:iTunSMPB".
+	key      string
+	dataType uint32
+	data     []byte
+}
+
+// text returns the value of the item as a string, if it contains text.
+func (i mp4Item) text() (string, bool) {
+	switch i.dataType {
+	case mp4DataTypeUTF8:
+		return string(i.data), true
+
+	case mp4DataTypeUTF16:
+		units := make([]uint16, len(i.data)/2)
+		for j := range units {
+			units[j] = binary.BigEndian.Uint16(i.data[2*j:])
+		}
+		return string(utf16.Decode(units)), true
+
+	default:
+		return "", false
+	}
+}
+
+// mp4Items contains the items of an ilst box, in order.
+type mp4Items []mp4Item
+
+// readMp4Items reads the iTunes-style metadata items from an MP4 file.
+func readMp4Items(r io.Reader) (mp4Items, error) {
+	moov, err := readMp4Moov(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ilst, err := findMp4Box(moov, "udta", "meta", "ilst")
+	if err != nil {
+		return nil, fmt.Errorf("ilst find failed: %w", err)
+	}
+
+	return parseMp4Items(ilst)
+}
+
+// parseMp4Items parses the body of an ilst box.
+func parseMp4Items(ilst []byte) (mp4Items, error) {
+	itemBoxes, err := parseMp4Boxes(ilst)
+	if err != nil {
+		return nil, fmt.Errorf("ilst parse failed: %w", err)
+	}
+
+	var items mp4Items
+	for _, itemBox := range itemBoxes {
+		children, err := parseMp4Boxes(itemBox.body)
+		if err != nil {
+			return nil, fmt.Errorf("%q item parse failed: %w", itemBox.boxType, err)
+		}
+
+		key := itemBox.boxType
+		if key == "----" {
+			var mean, name string
+			for _, child := range children {
+				// mean and name are full boxes, so skip the version and flags
+				if len(child.body) < 4 {
+					continue
+				}
+
+				switch child.boxType {
+				case "mean":
+					mean = string(child.body[4:])
+				case "name":
+					name = string(child.body[4:])
+				}
+			}
+			key = "----:" + mean + ":" + name
+		}
+
+		for _, child := range children {
+			if child.boxType != "data" {
+				continue
+			}
+
+			// 4 bytes of type indicator, then 4 bytes of locale
+			if len(child.body) < 8 {
+				return nil, fmt.Errorf("%q item data too short", itemBox.boxType)
+			}
+
+			items = append(items, mp4Item{
+				key:      key,
+				dataType: binary.BigEndian.Uint32(child.body[:4]) & 0xFFFFFF,
+				data:     child.body[8:],
+			})
+		}
+	}
+
+	return items, nil
+}
+
+// text returns the first text value for the given key, or "".
+func (items mp4Items) text(key string) string {
+	for _, item := range items {
+		if item.key != key {
+			continue
+		}
+
+		if s, ok := item.text(); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// freeform returns the first text value of the freeform item with the given
+// name, regardless of its mean, or "". Names are matched case-insensitively,
+// since writers disagree on their case.
+func (items mp4Items) freeform(name string) string {
+	for _, item := range items {
+		if !strings.HasPrefix(item.key, "----:") ||
+			!strings.EqualFold(item.key[strings.LastIndexByte(item.key, ':')+1:], name) {
+			continue
+		}
+
+		if s, ok := item.text(); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// mp4ItemNames maps item keys to friendly names.
+var mp4ItemNames = map[string]string{
+	"\xa9nam": "Title",
+	"\xa9ART": "Artist",
+	"aART":    "Album artist",
+	"\xa9alb": "Album",
+	"\xa9day": "Date",
+	"\xa9gen": "Genre",
+	"\xa9wrt": "Composer",
+	"\xa9cmt": "Comment",
+	"\xa9too": "Encoder",
+	"\xa9grp": "Grouping",
+	"\xa9lyr": "Lyrics",
+	"cprt":    "Copyright",
+	"desc":    "Description",
+	"trkn":    "Track number",
+	"disk":    "Disc number",
+	"tmpo":    "BPM",
+	"cpil":    "Compilation",
+}
+
+// metadata returns the items with human-readable values as a map.
+func (items mp4Items) metadata() map[string]string {
+	m := map[string]string{}
+
+	for _, item := range items {
+		name, ok := mp4ItemNames[item.key]
+		if !ok {
+			// the © in keys is a single Mac OS Roman byte, not UTF-8
+			name = strings.Replace(item.key, "\xa9", "©", 1)
+			if strings.HasPrefix(name, "----:") {
+				name = name[strings.LastIndexByte(name, ':')+1:]
+			}
+		}
+
+		var value string
+		switch {
+		case item.key == "trkn" || item.key == "disk":
+			// 2 reserved bytes, then the number and total as 16-bit integers
+			if len(item.data) < 6 {
+				continue
+			}
+			value = strconv.Itoa(int(binary.BigEndian.Uint16(item.data[2:])))
+			if total := binary.BigEndian.Uint16(item.data[4:]); total != 0 {
+				value += "/" + strconv.Itoa(int(total))
+			}
+
+		case item.dataType == mp4DataTypeBEInt ||
+			// these are sometimes stored as implicit instead of as integers
+			(item.dataType == mp4DataTypeImplicit && (item.key == "tmpo" || item.key == "cpil")):
+
+			var v int64
+			for _, b := range item.data {
+				v = v<<8 | int64(b)
+			}
+			value = strconv.FormatInt(v, 10)
+
+		default:
+			var ok bool
+			if value, ok = item.text(); !ok {
+				// skip binary data like cover art
+				continue
+			}
+		}
+
+		if prev, ok := m[name]; ok {
+			m[name] = prev + "; " + value
+		} else {
+			m[name] = value
+		}
+	}
+
+	return m
+}
+
+var mp4FormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return "", "", fmt.Errorf("item read failed: %w", err)
+		}
+
+		return items.text("\xa9nam"), items.text("\xa9ART"), nil
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return nil, fmt.Errorf("item read failed: %w", err)
+		}
+
+		// covr doesn't record picture types, but by convention the first
+		// image is the front cover
+		for _, item := range items {
+			if item.key != "covr" {
+				continue
+			}
+
+			switch item.dataType {
+			case mp4DataTypeJPEG, mp4DataTypePNG, mp4DataTypeImplicit:
+			default:
+				continue
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(item.data))
+			if err != nil {
+				return nil, fmt.Errorf("cover decode failed: %w", err)
+			}
+
+			return img, nil
+		}
+
+		return nil, nil
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return nil, fmt.Errorf("item read failed: %w", err)
+		}
+
+		return parseLyrics(items.text("\xa9lyr")), nil
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return nil, fmt.Errorf("item read failed: %w", err)
+		}
+
+		return items.metadata(), nil
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return nil, fmt.Errorf("item read failed: %w", err)
+		}
+
+		return parseReplayGain(items.freeform), nil
+	},
+	gapless: func(r io.Reader) (*gapless, error) {
+		items, err := readMp4Items(r)
+		if err != nil {
+			return nil, fmt.Errorf("item read failed: %w", err)
+		}
+
+		return parseITunSMPB(items.freeform("iTunSMPB")), nil
+	},
+}
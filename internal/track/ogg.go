@@ -0,0 +1,171 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// oggPageHeader is the header of a single page of an Ogg bitstream, see
+// https://www.rfc-editor.org/rfc/rfc3533#section-6.
+type oggPageHeader struct {
+	headerType      uint8
+	granulePosition int64
+	serial          uint32
+	sequence        uint32
+	// segments contains the lacing values of the page's segment table.
+	segments []byte
+}
+
+// bodyLen returns the total length of the page's body.
+func (h oggPageHeader) bodyLen() int64 {
+	var n int64
+	for _, s := range h.segments {
+		n += int64(s)
+	}
+	return n
+}
+
+// readOggPageHeader reads the header of the page that starts at the current
+// position of r.
+func readOggPageHeader(r io.Reader) (oggPageHeader, error) {
+	var raw [27]byte
+	if _, err := io.ReadFull(r, raw[:]); err != nil {
+		return oggPageHeader{}, err
+	}
+
+	if string(raw[:4]) != "OggS" {
+		return oggPageHeader{}, fmt.Errorf("invalid capture pattern % x", raw[:4])
+	}
+	if raw[4] != 0 {
+		return oggPageHeader{}, fmt.Errorf("unsupported stream structure version %d", raw[4])
+	}
+
+	h := oggPageHeader{
+		headerType:      raw[5],
+		granulePosition: int64(binary.LittleEndian.Uint64(raw[6:14])),
+		serial:          binary.LittleEndian.Uint32(raw[14:18]),
+		sequence:        binary.LittleEndian.Uint32(raw[18:22]),
+		segments:        make([]byte, raw[26]),
+	}
+	if _, err := io.ReadFull(r, h.segments); err != nil {
+		return oggPageHeader{}, fmt.Errorf("segment table read failed: %w", unexpectedEOF(err))
+	}
+
+	return h, nil
+}
+
+// oggPacketReader reads the packets of the first logical bitstream within an
+// Ogg physical bitstream. Pages belonging to other logical bitstreams are
+// skipped.
+type oggPacketReader struct {
+	r io.Reader
+
+	// page is the header of the page currently being read, and segmentIdx is
+	// the index of the next segment to be read from it.
+	page       oggPageHeader
+	segmentIdx int
+	// started indicates whether the first page has been read, meaning that
+	// page.serial is the serial number of the stream we're reading.
+	started bool
+}
+
+func newOggPacketReader(r io.Reader) *oggPacketReader {
+	return &oggPacketReader{r: r}
+}
+
+// nextPage advances to the next page of the logical bitstream being read.
+func (o *oggPacketReader) nextPage() error {
+	for {
+		h, err := readOggPageHeader(o.r)
+		if err != nil {
+			return err
+		}
+
+		if !o.started || h.serial == o.page.serial {
+			o.page = h
+			o.segmentIdx = 0
+			o.started = true
+			return nil
+		}
+
+		if err := skip(o.r, h.bodyLen()); err != nil {
+			return fmt.Errorf("page skip failed: %w", unexpectedEOF(err))
+		}
+	}
+}
+
+// next returns the next packet. It returns io.EOF once there are no more
+// packets.
+func (o *oggPacketReader) next() ([]byte, error) {
+	var packet []byte
+	for {
+		for o.segmentIdx >= len(o.page.segments) {
+			if err := o.nextPage(); err != nil {
+				if err == io.EOF && len(packet) != 0 {
+					return nil, io.ErrUnexpectedEOF
+				}
+
+				return nil, err
+			}
+		}
+
+		segmentLen := int(o.page.segments[o.segmentIdx])
+		o.segmentIdx++
+
+		start := len(packet)
+		packet = append(packet, make([]byte, segmentLen)...)
+		if _, err := io.ReadFull(o.r, packet[start:]); err != nil {
+			return nil, fmt.Errorf("segment read failed: %w", unexpectedEOF(err))
+		}
+
+		// a lacing value of less than 255 indicates the end of a packet
+		if segmentLen < 255 {
+			return packet, nil
+		}
+	}
+}
+
+// oggFormat determines the format of an Ogg file from its first packet, which
+// identifies the codec.
+func oggFormat(r io.Reader) (format, error) {
+	packet, err := newOggPacketReader(r).next()
+	if err != nil {
+		return 0, fmt.Errorf("first packet read failed: %w", unexpectedEOF(err))
+	}
+
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		return formatVorbis, nil
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		return formatOpus, nil
+	default:
+		return 0, &unknownFormatError{packet[:util.Min(len(packet), 8)]}
+	}
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, for use when EOF is
+// encountered partway through a structure.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// skip discards the next n bytes of r, seeking if possible.
+func skip(r io.Reader, n int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		return err
+	}
+
+	copied, err := io.CopyN(io.Discard, r, n)
+	if err == io.EOF && copied < n {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
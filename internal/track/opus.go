@@ -0,0 +1,451 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+	"github.com/pion/opus"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// opusSampleRate is the sample rate of decoded Opus audio. Granule positions
+// are always in units of 48 kHz samples, regardless of the input sample rate
+// in the identification header.
+const opusSampleRate = 48000
+
+// opusHead contains the fields we use from an Opus identification header,
+// see https://www.rfc-editor.org/rfc/rfc7845#section-5.1.
+type opusHead struct {
+	channels uint8
+	// preSkip is the number of samples to discard from the start of the
+	// decoded output.
+	preSkip uint16
+	// outputGain is the gain to apply to the decoded output, in dB, as a Q7.8
+	// fixed-point number.
+	outputGain int16
+}
+
+// readOpusHeaders reads the identification and comment headers from the start
+// of an Ogg Opus stream, and returns the packet reader positioned after them.
+func readOpusHeaders(r io.Reader) (*oggPacketReader, opusHead, vorbisComments, error) {
+	pr := newOggPacketReader(r)
+
+	identification, err := pr.next()
+	if err != nil {
+		return nil, opusHead{}, nil, fmt.Errorf("identification header read failed: %w", unexpectedEOF(err))
+	}
+	if len(identification) < 19 || !bytes.HasPrefix(identification, []byte("OpusHead")) {
+		return nil, opusHead{}, nil, fmt.Errorf("invalid identification header")
+	}
+	// only the major version, in the upper 4 bits, indicates incompatibility
+	if version := identification[8]; version>>4 != 0 {
+		return nil, opusHead{}, nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	head := opusHead{
+		channels:   identification[9],
+		preSkip:    binary.LittleEndian.Uint16(identification[10:]),
+		outputGain: int16(binary.LittleEndian.Uint16(identification[16:])),
+	}
+	if mappingFamily := identification[18]; mappingFamily != 0 {
+		return nil, opusHead{}, nil, fmt.Errorf("unsupported channel mapping family %d", mappingFamily)
+	}
+
+	comment, err := pr.next()
+	if err != nil {
+		return nil, opusHead{}, nil, fmt.Errorf("comment header read failed: %w", unexpectedEOF(err))
+	}
+	if !bytes.HasPrefix(comment, []byte("OpusTags")) {
+		return nil, opusHead{}, nil, fmt.Errorf("invalid comment header")
+	}
+
+	vc, err := parseVorbisComments(comment[len("OpusTags"):])
+	if err != nil {
+		return nil, opusHead{}, nil, fmt.Errorf("comment header parse failed: %w", err)
+	}
+
+	return pr, head, vc, nil
+}
+
+// readOpusTags reads the comment header of an Ogg Opus stream.
+func readOpusTags(r io.Reader) (vorbisComments, error) {
+	_, _, vc, err := readOpusHeaders(r)
+	return vc, err
+}
+
+// opusPacketSamples returns the number of samples, at 48 kHz, in an Opus
+// packet, see https://www.rfc-editor.org/rfc/rfc6716#section-3.1.
+func opusPacketSamples(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, fmt.Errorf("empty packet")
+	}
+
+	// the duration of each frame is determined by the configuration number
+	// in the top 5 bits of the TOC byte
+	var frameSamples int
+	switch config := packet[0] >> 3; {
+	case config < 12: // SILK-only: 10, 20, 40, or 60 ms
+		frameSamples = [...]int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // hybrid: 10 or 20 ms
+		frameSamples = [...]int{480, 960}[config%2]
+	default: // CELT-only: 2.5, 5, 10, or 20 ms
+		frameSamples = [...]int{120, 240, 480, 960}[config%4]
+	}
+
+	switch packet[0] & 0x3 {
+	case 0:
+		return frameSamples, nil
+	case 1, 2:
+		return 2 * frameSamples, nil
+	default:
+		if len(packet) < 2 {
+			return 0, fmt.Errorf("missing frame count")
+		}
+		return int(packet[1]&0x3F) * frameSamples, nil
+	}
+}
+
+// oggPageIndex records the position of a page within an Ogg file.
+type oggPageIndex struct {
+	offset          int64
+	granulePosition int64
+	// continued indicates that the page starts with the continuation of a
+	// packet from the previous page.
+	continued bool
+}
+
+// opusStream decodes an Ogg Opus stream. It always produces 48 kHz audio.
+type opusStream struct {
+	rsc      io.ReadSeekCloser
+	channels int
+	preSkip  int64
+	// gain is the linear gain to apply to decoded samples.
+	gain float32
+
+	// pages indexes the pages containing audio, for seeking. first is the
+	// granule position of the first sample of the first page, and end is the
+	// granule position after the last sample of the stream.
+	pages      []oggPageIndex
+	first, end int64
+
+	d  opus.Decoder
+	pr *oggPacketReader
+	// decoded is the granule position after the most recently decoded packet,
+	// and samples before skipTo are discarded instead of being streamed.
+	decoded, skipTo int64
+	pcm             []float32
+	// buf contains the samples that haven't been streamed yet, which start
+	// at granule position pos, and is backed by bufStorage.
+	buf        [][2]float64
+	bufStorage [][2]float64
+	pos        int64
+
+	err error
+}
+
+// maxOpusPacketSamples is the maximum number of samples per channel in a
+// single Opus packet, which is 120 ms at 48 kHz.
+const maxOpusPacketSamples = 5760
+
+// opusPreRoll is the number of samples that should be decoded and discarded
+// before the target of a seek, so that the decoder can converge, see
+// https://www.rfc-editor.org/rfc/rfc7845#section-4.6.
+const opusPreRoll = 3840
+
+// decodeOpus decodes an Ogg Opus file, which must be seekable.
+func decodeOpus(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	rsc, ok := rc.(io.ReadSeekCloser)
+	if !ok {
+		return nil, beep.Format{}, fmt.Errorf("reader is not seekable")
+	}
+
+	pr, head, _, err := readOpusHeaders(rsc)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if head.channels != 1 && head.channels != 2 {
+		return nil, beep.Format{}, fmt.Errorf("unsupported channel count %d", head.channels)
+	}
+	// the comment header must end its page, so audio starts on the next one
+	headerSequence := pr.page.sequence
+
+	s := &opusStream{
+		rsc:        rsc,
+		channels:   int(head.channels),
+		preSkip:    int64(head.preSkip),
+		gain:       float32(math.Pow(10, float64(head.outputGain)/(256*20))),
+		pcm:        make([]float32, maxOpusPacketSamples*int(head.channels)),
+		bufStorage: make([][2]float64, maxOpusPacketSamples),
+	}
+
+	if s.d, err = opus.NewDecoderWithOutput(opusSampleRate, s.channels); err != nil {
+		return nil, beep.Format{}, fmt.Errorf("decoder creation failed: %w", err)
+	}
+
+	if err := s.index(pr.page.serial, headerSequence); err != nil {
+		return nil, beep.Format{}, fmt.Errorf("page index failed: %w", err)
+	}
+
+	if err := s.Seek(0); err != nil {
+		return nil, beep.Format{}, err
+	}
+
+	return s, beep.Format{
+		SampleRate:  opusSampleRate,
+		NumChannels: s.channels,
+		Precision:   2,
+	}, nil
+}
+
+// index reads the headers of the audio pages of the logical bitstream with
+// the given serial number, which follow the page with the given sequence
+// number, and determines the range of granule positions they contain.
+func (s *opusStream) index(serial, headerSequence uint32) error {
+	if _, err := s.rsc.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+
+	var offset int64
+	for {
+		h, err := readOggPageHeader(s.rsc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("page header read failed: %w", unexpectedEOF(err))
+		}
+
+		if h.serial == serial && h.sequence > headerSequence {
+			s.pages = append(s.pages, oggPageIndex{
+				offset:          offset,
+				granulePosition: h.granulePosition,
+				continued:       h.headerType&0x01 != 0,
+			})
+			if h.granulePosition != -1 {
+				s.end = h.granulePosition
+			}
+		}
+
+		if err := skip(s.rsc, h.bodyLen()); err != nil {
+			return fmt.Errorf("page skip failed: %w", unexpectedEOF(err))
+		}
+		offset += 27 + int64(len(h.segments)) + h.bodyLen()
+	}
+
+	if len(s.pages) == 0 {
+		return fmt.Errorf("no audio pages")
+	}
+
+	// the granule position of the first page is that of its last sample, so
+	// the durations of its packets have to be subtracted to find the first
+	if _, err := s.rsc.Seek(s.pages[0].offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+	pr := newOggPacketReader(s.rsc)
+	if err := pr.nextPage(); err != nil {
+		return fmt.Errorf("first page read failed: %w", unexpectedEOF(err))
+	}
+	firstPage := pr.page
+	var samples int64
+	for pr.page.sequence == firstPage.sequence && pr.segmentIdx < len(pr.page.segments) {
+		packet, err := pr.next()
+		if err != nil {
+			return fmt.Errorf("first packet read failed: %w", unexpectedEOF(err))
+		}
+		if pr.page.sequence != firstPage.sequence {
+			// this packet ends on a later page
+			break
+		}
+
+		n, err := opusPacketSamples(packet)
+		if err != nil {
+			return fmt.Errorf("first packet parse failed: %w", err)
+		}
+		samples += int64(n)
+	}
+
+	// if the first page is also the last, a granule position less than the
+	// number of samples indicates trimming at the end instead
+	s.first = util.Max(firstPage.granulePosition-samples, 0)
+	if s.end < s.first+s.preSkip {
+		s.end = s.first + s.preSkip
+	}
+
+	return nil
+}
+
+// fill decodes packets until there are samples to stream in s.buf. It returns
+// false if there are no more samples, or if an error occurs.
+func (s *opusStream) fill() bool {
+	for s.decoded < s.end {
+		packet, err := s.pr.next()
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			s.err = fmt.Errorf("packet read failed: %w", err)
+			return false
+		}
+		if len(packet) == 0 {
+			continue
+		}
+
+		n, err := s.d.DecodeToFloat32(packet, s.pcm)
+		if err != nil {
+			s.err = fmt.Errorf("packet decode failed: %w", err)
+			return false
+		}
+
+		start := s.decoded
+		s.decoded += int64(n)
+
+		from, to := util.Max(start, s.skipTo), util.Min(s.decoded, s.end)
+		if from >= to {
+			continue
+		}
+
+		s.buf = s.bufStorage[:to-from]
+		for i := range s.buf {
+			j := (int(from-start) + i) * s.channels
+			s.buf[i][0] = float64(s.pcm[j] * s.gain)
+			s.buf[i][1] = float64(s.pcm[j+s.channels-1] * s.gain)
+		}
+		s.pos = from
+		return true
+	}
+
+	return false
+}
+
+func (s *opusStream) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.err != nil {
+		return 0, false
+	}
+
+	for n < len(samples) {
+		if len(s.buf) == 0 && !s.fill() {
+			break
+		}
+
+		copied := copy(samples[n:], s.buf)
+		s.buf = s.buf[copied:]
+		s.pos += int64(copied)
+		n += copied
+	}
+
+	return n, n > 0
+}
+
+func (s *opusStream) Err() error {
+	return s.err
+}
+
+func (s *opusStream) Len() int {
+	return int(s.end - s.first - s.preSkip)
+}
+
+func (s *opusStream) Position() int {
+	return int(s.pos - s.first - s.preSkip)
+}
+
+// Seek moves to sample p. Decoding starts from the last page that begins with
+// a new packet at least opusPreRoll samples before p.
+func (s *opusStream) Seek(p int) error {
+	if p < 0 || p > s.Len() {
+		return fmt.Errorf("seek position %d out of range [0, %d]", p, s.Len())
+	}
+
+	target := s.first + s.preSkip + int64(p)
+	start := util.Max(target-opusPreRoll, s.first)
+
+	// the first page always begins with a new packet
+	page, decoded := s.pages[0], s.first
+	for i := 0; i+1 < len(s.pages); i++ {
+		granulePosition := s.pages[i].granulePosition
+		if granulePosition > start {
+			break
+		}
+		if granulePosition != -1 && !s.pages[i+1].continued {
+			page, decoded = s.pages[i+1], granulePosition
+		}
+	}
+
+	if _, err := s.rsc.Seek(page.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+	if err := s.d.Init(opusSampleRate, s.channels); err != nil {
+		return fmt.Errorf("decoder reset failed: %w", err)
+	}
+
+	s.pr = newOggPacketReader(s.rsc)
+	s.decoded, s.skipTo = decoded, target
+	s.buf = nil
+	s.pos = target
+	s.err = nil
+	return nil
+}
+
+func (s *opusStream) Close() error {
+	return s.rsc.Close()
+}
+
+var opusFormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		vc, err := readOpusTags(r)
+		if err != nil {
+			return "", "", err
+		}
+
+		title, artist = vc.info()
+		return title, artist, nil
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		vc, err := readOpusTags(r)
+		if err != nil {
+			return nil, err
+		}
+
+		pictures, err := vc.pictures()
+		if err != nil {
+			return nil, err
+		}
+
+		return pictureCover(pictures)
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		vc, err := readOpusTags(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return vc.lyrics(), nil
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		vc, err := readOpusTags(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return vc.metadata(), nil
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		vc, err := readOpusTags(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// the R128 tags are the standard for Opus, but some taggers write
+		// the usual ReplayGain tags instead
+		if rg := parseR128Gain(vc.get); rg != nil {
+			return rg, nil
+		}
+		return vc.replayGain(), nil
+	},
+	decode: decodeOpus,
+}
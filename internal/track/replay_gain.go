@@ -0,0 +1,90 @@
+package track
+
+import (
+	"strconv"
+	"strings"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// ReplayGain contains the ReplayGain values of a track, see
+// https://wiki.hydrogenaud.io/index.php?title=ReplayGain_2.0_specification.
+type ReplayGain struct {
+	// Track contains the values for the track on its own. It is nil if they
+	// weren't found.
+	Track *ReplayGainValues
+
+	// Album contains the values for the album the track belongs to. It is nil
+	// if they weren't found.
+	Album *ReplayGainValues
+}
+
+// ReplayGainValues contains the gain and peak for either a track or an album.
+type ReplayGainValues struct {
+	// Gain is the gain in dB that brings the audio to the reference loudness
+	// of -18 LUFS.
+	Gain float64
+
+	// Peak is the peak sample amplitude, where 1 is full scale. It is 0 if it
+	// wasn't found.
+	Peak float64
+}
+
+// parseReplayGain parses the standard REPLAYGAIN_* tags, which are looked up
+// with get. It returns nil if there are no gain tags.
+func parseReplayGain(get func(name string) string) *ReplayGain {
+	rg := &ReplayGain{
+		Track: parseReplayGainValues(get("REPLAYGAIN_TRACK_GAIN"), get("REPLAYGAIN_TRACK_PEAK")),
+		Album: parseReplayGainValues(get("REPLAYGAIN_ALBUM_GAIN"), get("REPLAYGAIN_ALBUM_PEAK")),
+	}
+	if rg.Track == nil && rg.Album == nil {
+		return nil
+	}
+
+	return rg
+}
+
+// parseReplayGainValues parses a gain such as "-6.48 dB", and a peak such as
+// "0.988312". It returns nil if the gain is missing or invalid.
+func parseReplayGainValues(gain, peak string) *ReplayGainValues {
+	gainFields := strings.Fields(gain)
+	if len(gainFields) == 0 {
+		return nil
+	}
+
+	g, err := strconv.ParseFloat(gainFields[0], 64)
+	if err != nil {
+		return nil
+	}
+
+	// the peak is optional, so an invalid one is left as unknown
+	p, _ := strconv.ParseFloat(strings.TrimSpace(peak), 64)
+
+	return &ReplayGainValues{Gain: g, Peak: util.Max(p, 0)}
+}
+
+// parseR128Gain parses the R128_TRACK_GAIN and R128_ALBUM_GAIN tags of Opus
+// files, see https://datatracker.ietf.org/doc/html/rfc7845#section-5.2.1,
+// which are looked up with get. It returns nil if there are no such tags.
+func parseR128Gain(get func(name string) string) *ReplayGain {
+	parse := func(s string) *ReplayGainValues {
+		// Q7.8 fixed point values in dB
+		q, err := strconv.ParseInt(strings.TrimSpace(s), 10, 16)
+		if err != nil {
+			return nil
+		}
+
+		// the reference loudness is -23 LUFS instead of -18 LUFS
+		return &ReplayGainValues{Gain: float64(q)/256 + 5}
+	}
+
+	rg := &ReplayGain{
+		Track: parse(get("R128_TRACK_GAIN")),
+		Album: parse(get("R128_ALBUM_GAIN")),
+	}
+	if rg.Track == nil && rg.Album == nil {
+		return nil
+	}
+
+	return rg
+}
@@ -2,6 +2,7 @@ package track
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"io"
@@ -10,9 +11,6 @@ import (
 	"sync"
 
 	"github.com/faiface/beep"
-	"github.com/faiface/beep/flac"
-	"github.com/faiface/beep/vorbis"
-	"github.com/faiface/beep/wav"
 )
 
 type format uint8
@@ -23,6 +21,8 @@ const (
 	formatWav
 	formatVorbis
 	formatMp4
+	formatOpus
+	formatAiff
 )
 
 func (f format) String() string {
@@ -37,6 +37,10 @@ func (f format) String() string {
 		return "vorbis"
 	case formatMp4:
 		return "mp4"
+	case formatOpus:
+		return "opus"
+	case formatAiff:
+		return "aiff"
 	default:
 		return "<invalid format>"
 	}
@@ -47,12 +51,13 @@ var _ fmt.Stringer = format(0)
 // formatHandler defines functions for handling a given audio format. Functions
 // may be nil, which indicates that the operation is not supported.
 type formatHandler struct {
-	info  func(io.Reader) (title, artist string, err error)
-	cover func(io.Reader) (image.Image, error)
-	// TODO: support lyrics with timestamps
-	lyrics   func(io.Reader) (string, error)
-	metadata func(io.Reader) (map[string]string, error)
-	decode   func(io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error)
+	info       func(io.Reader) (title, artist string, err error)
+	cover      func(io.Reader) (image.Image, error)
+	lyrics     func(io.Reader) (*Lyrics, error)
+	metadata   func(io.Reader) (map[string]string, error)
+	replayGain func(io.Reader) (*ReplayGain, error)
+	gapless    func(io.Reader) (*gapless, error)
+	decode     func(io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error)
 }
 
 // wrapReaderDecoder converts a decode function that takes an io.Reader to one
@@ -67,17 +72,13 @@ func wrapReaderDecoder(d func(io.Reader) (beep.StreamSeekCloser, beep.Format, er
 // supported by this player.
 var formatHandlers = [...]*formatHandler{
 	// TODO: fill this out more
-	formatMp3: mp3FormatHandler,
-	formatFlac: {
-		decode: wrapReaderDecoder(flac.Decode),
-	},
-	formatWav: {
-		decode: wrapReaderDecoder(wav.Decode),
-	},
-	formatVorbis: {
-		decode: vorbis.Decode,
-	},
-	formatMp4: nil,
+	formatMp3:    mp3FormatHandler,
+	formatFlac:   flacFormatHandler,
+	formatWav:    wavFormatHandler,
+	formatVorbis: vorbisFormatHandler,
+	formatMp4:    mp4FormatHandler,
+	formatOpus:   opusFormatHandler,
+	formatAiff:   aiffFormatHandler,
 }
 
 // Track represents a song on the filesystem. This type must not be copied
@@ -98,12 +99,16 @@ type Track struct {
 	coverErr  error
 
 	lyricsOnce sync.Once
-	lyrics     string
+	lyrics     *Lyrics
 	lyricsErr  error
 
 	metadataOnce sync.Once
 	metadata     map[string]string
 	metadataErr  error
+
+	replayGainOnce sync.Once
+	replayGain     *ReplayGain
+	replayGainErr  error
 }
 
 type unknownFormatError struct {
@@ -114,6 +119,13 @@ func (ufe *unknownFormatError) Error() string {
 	return fmt.Sprintf("unknown format with magic % x", ufe.magic)
 }
 
+// IsUnknownFormat reports whether err was caused by a file not being in any
+// supported format.
+func IsUnknownFormat(err error) bool {
+	var ufe *unknownFormatError
+	return errors.As(err, &ufe)
+}
+
 func (t *Track) initFormat() {
 	t.formatOnce.Do(func() {
 		f, err := os.Open(t.Path)
@@ -121,6 +133,7 @@ func (t *Track) initFormat() {
 			t.formatErr = err
 			return
 		}
+		defer func() { _ = f.Close() }() // intentionally ignore close error
 
 		var magic [12]byte
 		_, err = f.Read(magic[:])
@@ -139,12 +152,22 @@ func (t *Track) initFormat() {
 			t.format = formatMp3
 		case bytes.Compare(magic[:4], []byte("fLaC")) == 0:
 			t.format = formatFlac
-		case bytes.Compare(magic[:4], []byte("RIFF")) == 0 ||
+		case bytes.Compare(magic[:4], []byte("RIFF")) == 0 &&
 			bytes.Compare(magic[8:12], []byte("WAVE")) == 0:
 			t.format = formatWav
 		case bytes.Compare(magic[:4], []byte("OggS")) == 0:
-			t.format = formatVorbis
-		case bytes.Compare(magic[:8], []byte("ftypisom")) == 0:
+			// Ogg is just a container, so the codec has to be determined
+			// from the first packet
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.formatErr = err
+				return
+			}
+			t.format, t.formatErr = oggFormat(f)
+		case bytes.Compare(magic[:4], []byte("FORM")) == 0 &&
+			(bytes.Compare(magic[8:12], []byte("AIFF")) == 0 ||
+				bytes.Compare(magic[8:12], []byte("AIFC")) == 0):
+			t.format = formatAiff
+		case isMp4(magic[:]):
 			t.format = formatMp4
 		default:
 			t.formatErr = &unknownFormatError{magic[:]}
@@ -220,64 +243,103 @@ func (t *Track) Artist() (string, error) {
 	return t.artist, t.infoErr
 }
 
-// Cover returns the cover image of this track, if it has one. This function
-// will return nil, nil if no error is encountered and the file does not have
-// a cover image.
-func (t *Track) Cover() (image.Image, error) {
+// Cover returns the cover image of this track, if it has one. If there is no
+// cover image embedded in the file, an image in the same directory with one of
+// sidecarNames, in order of priority, is used instead. The result is cached, so
+// sidecarNames should be the same every time. This function will return nil,
+// nil if no error is encountered and no cover image is found.
+func (t *Track) Cover(sidecarNames []string) (image.Image, error) {
 	t.coverOnce.Do(func() {
-		if t.initFormat(); t.formatErr != nil {
-			t.infoErr = fmt.Errorf("format error: %w", t.formatErr)
-			return
-		}
-
-		handlers := formatHandlers[t.format]
-		if handlers == nil || handlers.cover == nil {
-			// leave the cover nil, since that's allowed
+		t.cover, t.coverErr = t.embeddedCover()
+		if t.coverErr != nil || t.cover != nil {
 			return
 		}
 
-		f, err := os.Open(t.Path)
-		if err != nil {
-			t.coverErr = fmt.Errorf("open failed: %w", err)
-			return
+		t.cover, t.coverErr = readSidecarCover(filepath.Dir(t.Path), sidecarNames)
+		if t.coverErr != nil {
+			t.coverErr = fmt.Errorf("sidecar read failed: %w", t.coverErr)
 		}
-		defer func() { _ = f.Close() }() // intentionally ignore close error
-
-		t.cover, t.coverErr = handlers.cover(f)
 	})
 
 	return t.cover, t.coverErr
 }
 
-// Lyrics returns the lyrics for this track, if it has any. This function will
-// return nil, nil if no error is encountered and the file does not have any
-// lyrics.
-func (t *Track) Lyrics() (string, error) {
-	t.lyricsOnce.Do(func() {
-		if t.initFormat(); t.formatErr != nil {
-			t.infoErr = fmt.Errorf("format error: %w", t.formatErr)
-			return
+// embeddedCover reads the cover image embedded in this track's file.
+func (t *Track) embeddedCover() (image.Image, error) {
+	if t.initFormat(); t.formatErr != nil {
+		if _, ok := t.formatErr.(*unknownFormatError); ok {
+			// there might still be a sidecar file
+			return nil, nil
 		}
 
-		handlers := formatHandlers[t.format]
-		if handlers == nil || handlers.lyrics == nil {
-			// leave the lyrics empty
+		return nil, fmt.Errorf("format error: %w", t.formatErr)
+	}
+
+	handlers := formatHandlers[t.format]
+	if handlers == nil || handlers.cover == nil {
+		// leave the cover nil, since that's allowed
+		return nil, nil
+	}
+
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open failed: %w", err)
+	}
+	defer func() { _ = f.Close() }() // intentionally ignore close error
+
+	return handlers.cover(f)
+}
+
+// Lyrics returns the lyrics for this track, if it has any. Lyrics embedded in
+// the file are preferred, unless they aren't synced and there is a .lrc file
+// with the same basename next to the file. This function will return nil, nil
+// if no error is encountered and no lyrics are found.
+func (t *Track) Lyrics() (*Lyrics, error) {
+	t.lyricsOnce.Do(func() {
+		t.lyrics, t.lyricsErr = t.embeddedLyrics()
+		if t.lyricsErr != nil || t.lyrics != nil && t.lyrics.Synced {
 			return
 		}
 
-		f, err := os.Open(t.Path)
+		sidecar, err := readSidecarLyrics(t.Path)
 		if err != nil {
-			t.lyricsErr = fmt.Errorf("open failed: %w", err)
+			t.lyricsErr = fmt.Errorf("sidecar read failed: %w", err)
 			return
 		}
-		defer func() { _ = f.Close() }() // intentionally ignore close error
-
-		t.lyrics, t.lyricsErr = handlers.lyrics(f)
+		if sidecar != nil {
+			t.lyrics = sidecar
+		}
 	})
 
 	return t.lyrics, t.lyricsErr
 }
 
+// embeddedLyrics reads the lyrics embedded in this track's file.
+func (t *Track) embeddedLyrics() (*Lyrics, error) {
+	if t.initFormat(); t.formatErr != nil {
+		if _, ok := t.formatErr.(*unknownFormatError); ok {
+			// there might still be a sidecar file
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("format error: %w", t.formatErr)
+	}
+
+	handlers := formatHandlers[t.format]
+	if handlers == nil || handlers.lyrics == nil {
+		// leave the lyrics empty
+		return nil, nil
+	}
+
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open failed: %w", err)
+	}
+	defer func() { _ = f.Close() }() // intentionally ignore close error
+
+	return handlers.lyrics(f)
+}
+
 // Metadata returns all metadata for this track, if it can be fetched. This
 // function will return nil, nil if no error is encountered but no metadata can
 // be found.
@@ -307,6 +369,35 @@ func (t *Track) Metadata() (map[string]string, error) {
 	return t.metadata, t.metadataErr
 }
 
+// ReplayGain returns the ReplayGain values for this track, if it has any. This
+// function will return nil, nil if no error is encountered but no values can
+// be found.
+func (t *Track) ReplayGain() (*ReplayGain, error) {
+	t.replayGainOnce.Do(func() {
+		if t.initFormat(); t.formatErr != nil {
+			t.replayGainErr = fmt.Errorf("format error: %w", t.formatErr)
+			return
+		}
+
+		handlers := formatHandlers[t.format]
+		if handlers == nil || handlers.replayGain == nil {
+			// leave the values nil
+			return
+		}
+
+		f, err := os.Open(t.Path)
+		if err != nil {
+			t.replayGainErr = fmt.Errorf("open failed: %w", err)
+			return
+		}
+		defer func() { _ = f.Close() }() // intentionally ignore close error
+
+		t.replayGain, t.replayGainErr = handlers.replayGain(f)
+	})
+
+	return t.replayGain, t.replayGainErr
+}
+
 // Decode returns a beep.StreamSeekCloser and beep.Format for this track.
 //
 // It is the caller's responsibility to close the beep.StreamSeekCloser when
@@ -327,5 +418,34 @@ func (t *Track) Decode() (beep.StreamSeekCloser, beep.Format, error) {
 	}
 	// don't close, will be closed when the StreamSeekCloser gets closed
 
-	return handlers.decode(f)
+	raw, format, err := handlers.decode(f)
+	if err != nil || handlers.gapless == nil {
+		return raw, format, err
+	}
+
+	g, err := t.gapless(handlers)
+	if err != nil || g == nil {
+		// the gapless info is optional, so the track is still playable
+		// without it, just untrimmed
+		return raw, format, nil
+	}
+
+	streamer, err := newGaplessStreamSeekCloser(raw, *g)
+	if err != nil {
+		_ = raw.Close() // intentionally ignore close error
+		return nil, beep.Format{}, fmt.Errorf("gapless trim failed: %w", err)
+	}
+
+	return streamer, format, nil
+}
+
+// gapless reads the gapless info for this track using handlers.
+func (t *Track) gapless(handlers *formatHandler) (*gapless, error) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open failed: %w", err)
+	}
+	defer func() { _ = f.Close() }() // intentionally ignore close error
+
+	return handlers.gapless(f)
 }
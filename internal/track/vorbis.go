@@ -0,0 +1,91 @@
+package track
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/faiface/beep/vorbis"
+)
+
+// readVorbisComments reads the comment header of an Ogg Vorbis stream, which is
+// the second packet of the stream, see
+// https://xiph.org/vorbis/doc/Vorbis_I_spec.html#x1-610004.2
+func readVorbisComments(r io.Reader) (vorbisComments, error) {
+	pr := newOggPacketReader(r)
+
+	identification, err := pr.next()
+	if err != nil {
+		return nil, fmt.Errorf("identification header read failed: %w", unexpectedEOF(err))
+	}
+	if !bytes.HasPrefix(identification, []byte("\x01vorbis")) {
+		return nil, fmt.Errorf("invalid identification header")
+	}
+
+	comment, err := pr.next()
+	if err != nil {
+		return nil, fmt.Errorf("comment header read failed: %w", unexpectedEOF(err))
+	}
+	if !bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+		return nil, fmt.Errorf("invalid comment header")
+	}
+
+	// the trailing framing bit is left in, but it'll just get ignored
+	vc, err := parseVorbisComments(comment[len("\x03vorbis"):])
+	if err != nil {
+		return nil, fmt.Errorf("comment header parse failed: %w", err)
+	}
+
+	return vc, nil
+}
+
+var vorbisFormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		vc, err := readVorbisComments(r)
+		if err != nil {
+			return "", "", err
+		}
+
+		title, artist = vc.info()
+		return title, artist, nil
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		vc, err := readVorbisComments(r)
+		if err != nil {
+			return nil, err
+		}
+
+		pictures, err := vc.pictures()
+		if err != nil {
+			return nil, err
+		}
+
+		return pictureCover(pictures)
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		vc, err := readVorbisComments(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return vc.lyrics(), nil
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		vc, err := readVorbisComments(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return vc.metadata(), nil
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		vc, err := readVorbisComments(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return vc.replayGain(), nil
+	},
+	decode: vorbis.Decode,
+}
@@ -0,0 +1,194 @@
+package track
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/mewkiz/flac/meta"
+)
+
+// vorbisComments contains the name-value pairs of a Vorbis comment block, as
+// used by FLAC, Ogg Vorbis, and Opus files.
+type vorbisComments [][2]string
+
+// parseVorbisComments parses the contents of a Vorbis comment header, without
+// any codec-specific prefix or framing, see
+// https://www.xiph.org/vorbis/doc/v-comment.html#structure
+func parseVorbisComments(b []byte) (vorbisComments, error) {
+	// readString reads a length-prefixed string from the start of b
+	readString := func() (string, error) {
+		if len(b) < 4 {
+			return "", fmt.Errorf("truncated length")
+		}
+		n := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+
+		if uint64(len(b)) < uint64(n) {
+			return "", fmt.Errorf("truncated string")
+		}
+		s := string(b[:n])
+		b = b[n:]
+
+		return s, nil
+	}
+
+	if _, err := readString(); err != nil {
+		return nil, fmt.Errorf("vendor read failed: %w", err)
+	}
+
+	if len(b) < 4 {
+		return nil, fmt.Errorf("truncated comment count")
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	var vc vorbisComments
+	for i := uint32(0); i < count; i++ {
+		comment, err := readString()
+		if err != nil {
+			return nil, fmt.Errorf("comment %d read failed: %w", i, err)
+		}
+
+		name, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			// the specification says to ignore comments without a separator
+			continue
+		}
+
+		vc = append(vc, [2]string{name, value})
+	}
+
+	return vc, nil
+}
+
+// get returns the first value for the field with the given name, or "" if
+// there is no such field. Field names are case-insensitive, see
+// https://www.xiph.org/vorbis/doc/v-comment.html#vectorformat
+func (vc vorbisComments) get(name string) string {
+	for _, tag := range vc {
+		if strings.EqualFold(tag[0], name) {
+			return tag[1]
+		}
+	}
+
+	return ""
+}
+
+// info returns the title and artist.
+func (vc vorbisComments) info() (title, artist string) {
+	return vc.get("TITLE"), vc.get("ARTIST")
+}
+
+// lyrics returns the lyrics, which are stored under one of a couple of
+// non-standard, but commonly used field names.
+func (vc vorbisComments) lyrics() *Lyrics {
+	if lyrics := vc.get("LYRICS"); lyrics != "" {
+		return parseLyrics(lyrics)
+	}
+
+	return parseLyrics(vc.get("UNSYNCEDLYRICS"))
+}
+
+// replayGain returns the ReplayGain values, if there are any.
+func (vc vorbisComments) replayGain() *ReplayGain {
+	return parseReplayGain(vc.get)
+}
+
+// metadata returns all fields as a map, with upper-case field names as keys.
+// Fields that appear multiple times (such as multiple artists) have their
+// values joined.
+func (vc vorbisComments) metadata() map[string]string {
+	m := map[string]string{}
+
+	for _, tag := range vc {
+		name := strings.ToUpper(tag[0])
+		if name == "METADATA_BLOCK_PICTURE" {
+			// this is a big binary blob that doesn't belong in a map of
+			// human-readable values
+			continue
+		}
+
+		if prev, ok := m[name]; ok {
+			m[name] = prev + "; " + tag[1]
+		} else {
+			m[name] = tag[1]
+		}
+	}
+
+	return m
+}
+
+// pictures returns the pictures stored in METADATA_BLOCK_PICTURE fields, which
+// contain base64-encoded FLAC picture blocks, see
+// https://wiki.xiph.org/VorbisComment#Cover_art
+func (vc vorbisComments) pictures() ([]*meta.Picture, error) {
+	var pictures []*meta.Picture
+	for _, tag := range vc {
+		if !strings.EqualFold(tag[0], "METADATA_BLOCK_PICTURE") {
+			continue
+		}
+
+		body, err := base64.StdEncoding.DecodeString(tag[1])
+		if err != nil {
+			return nil, fmt.Errorf("picture base64 decode failed: %w", err)
+		}
+
+		// prefix a block header so we can reuse the FLAC picture parser; the
+		// length field is only 24 bits wide
+		if len(body) >= 1<<24 {
+			return nil, fmt.Errorf("picture too large: %d bytes", len(body))
+		}
+		header := []byte{
+			byte(meta.TypePicture),
+			byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body)),
+		}
+
+		block, err := meta.Parse(bytes.NewReader(append(header, body...)))
+		if err != nil {
+			return nil, fmt.Errorf("picture parse failed: %w", err)
+		}
+
+		pictures = append(pictures, block.Body.(*meta.Picture))
+	}
+
+	return pictures, nil
+}
+
+// pictureCover decodes the front cover from pictures, which uses the same
+// picture types as ID3v2 APIC frames. If there's no front cover, the first
+// picture is used instead. It returns nil, nil if there are no pictures.
+func pictureCover(pictures []*meta.Picture) (image.Image, error) {
+	var cover *meta.Picture
+	for _, p := range pictures {
+		// "-->" indicates that the data is a URL instead of the picture
+		// itself, which we don't try to fetch
+		if p.MIME == "-->" {
+			continue
+		}
+
+		// 3 is "Cover (front)", see https://id3.org/id3v2.3.0#Attached_picture
+		if p.Type == 3 {
+			cover = p
+			break
+		}
+
+		if cover == nil {
+			cover = p
+		}
+	}
+
+	if cover == nil {
+		return nil, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(cover.Data))
+	if err != nil {
+		return nil, fmt.Errorf("cover decode failed: %w", err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,212 @@
+package track
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"unicode/utf8"
+
+	"github.com/faiface/beep/wav"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// wavTags contains the tags of a WAV file, which can be stored in a LIST INFO
+// chunk, an id3 chunk, or both.
+type wavTags struct {
+	// info maps the IDs of the LIST INFO chunk's subchunks to their values.
+	info map[string]string
+	// id3 is the contents of the id3 chunk, which the id3 functions treat as
+	// an empty tag if there isn't one.
+	id3 io.Reader
+}
+
+// wavInfoNames maps LIST INFO subchunk IDs to friendly names, see
+// https://www.recordingblogs.com/wiki/list-chunk-of-a-wave-file
+var wavInfoNames = map[string]string{
+	"INAM": "Title",
+	"IART": "Artist",
+	"IPRD": "Album",
+	"ICRD": "Date",
+	"IGNR": "Genre",
+	"ICMT": "Comment",
+	"ICOP": "Copyright",
+	"IENG": "Engineer",
+	"ISFT": "Encoder",
+	"ISBJ": "Subject",
+	"IKEY": "Keywords",
+	"ISRC": "Source",
+	"ITCH": "Technician",
+	"ITRK": "Track number",
+}
+
+// readWavTags reads the tags from a RIFF WAVE file, see
+// https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/WAVE/WAVE.html. Only
+// the id3 chunk is read if withInfo is false.
+func readWavTags(r io.Reader, withInfo bool) (wavTags, error) {
+	formType, err := readIffHeader(r, "RIFF")
+	if err != nil {
+		return wavTags{}, err
+	}
+	if formType != "WAVE" {
+		return wavTags{}, fmt.Errorf("invalid form type %q", formType)
+	}
+
+	tags := wavTags{info: map[string]string{}}
+	var id3 []byte
+	err = walkIffChunks(r, binary.LittleEndian, func(c iffChunk) error {
+		switch c.id {
+		// the chunk ID isn't standardized, so taggers use either case
+		case "id3 ", "ID3 ":
+			if id3 != nil {
+				return nil
+			}
+
+			var err error
+			if id3, err = io.ReadAll(c.body); err != nil {
+				return fmt.Errorf("%q chunk read failed: %w", c.id, err)
+			}
+
+		case "LIST":
+			if !withInfo {
+				return nil
+			}
+
+			var listType [4]byte
+			if _, err := io.ReadFull(c.body, listType[:]); err != nil {
+				return fmt.Errorf("%q chunk read failed: %w", c.id, unexpectedEOF(err))
+			}
+			if string(listType[:]) != "INFO" {
+				return nil
+			}
+
+			b, err := io.ReadAll(c.body)
+			if err != nil {
+				return fmt.Errorf("%q chunk read failed: %w", c.id, err)
+			}
+			if err := parseWavInfo(b, tags.info); err != nil {
+				return fmt.Errorf("%q chunk parse failed: %w", c.id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return wavTags{}, err
+	}
+
+	tags.id3 = bytes.NewReader(id3)
+	return tags, nil
+}
+
+// parseWavInfo parses the subchunks of a LIST INFO chunk into info.
+func parseWavInfo(b []byte, info map[string]string) error {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return fmt.Errorf("subchunk header too short")
+		}
+		id := string(b[:4])
+		size := int(binary.LittleEndian.Uint32(b[4:]))
+		b = b[8:]
+		if size > len(b) {
+			return fmt.Errorf("%q subchunk too long", id)
+		}
+
+		// values are null-terminated, but not every writer includes the null
+		value := b[:size]
+		if i := bytes.IndexByte(value, 0); i != -1 {
+			value = value[:i]
+		}
+		if value := decodeWavText(value); value != "" {
+			info[id] = value
+		}
+
+		// subchunks are padded to an even size, like chunks
+		b = b[util.Min(size+size&1, len(b)):]
+	}
+
+	return nil
+}
+
+// decodeWavText decodes the text of a LIST INFO subchunk. The text is supposed
+// to be ASCII, but writers use UTF-8 or Latin-1 in practice, and the latter is
+// assumed for anything that isn't valid UTF-8.
+func decodeWavText(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	return decodeID3Text(b, 0)
+}
+
+var wavFormatHandler = &formatHandler{
+	info: func(r io.Reader) (title string, artist string, err error) {
+		tags, err := readWavTags(r, true)
+		if err != nil {
+			return "", "", err
+		}
+
+		// the id3 chunk takes priority, since it's less limited
+		if title, artist, err = id3Info(tags.id3); err != nil {
+			return "", "", err
+		}
+		if title == "" {
+			title = tags.info["INAM"]
+		}
+		if artist == "" {
+			artist = tags.info["IART"]
+		}
+		return title, artist, nil
+	},
+	cover: func(r io.Reader) (image.Image, error) {
+		tags, err := readWavTags(r, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3Cover(tags.id3)
+	},
+	lyrics: func(r io.Reader) (*Lyrics, error) {
+		tags, err := readWavTags(r, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3Lyrics(tags.id3)
+	},
+	metadata: func(r io.Reader) (map[string]string, error) {
+		tags, err := readWavTags(r, true)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := id3Metadata(tags.id3)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, value := range tags.info {
+			name, ok := wavInfoNames[id]
+			if !ok {
+				name = id
+			}
+
+			// the id3 chunk takes priority, since it's less limited
+			if _, ok := m[name]; !ok {
+				m[name] = value
+			}
+		}
+
+		return m, nil
+	},
+	replayGain: func(r io.Reader) (*ReplayGain, error) {
+		tags, err := readWavTags(r, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return id3ReplayGain(tags.id3)
+	},
+	decode: wrapReaderDecoder(wav.Decode),
+}
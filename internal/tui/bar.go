@@ -68,6 +68,26 @@ func (t *tui) drawBar() {
 			strings.Repeat(" ", barW-barCompleteW-1), styleDefault)
 	}
 
+	// the markers can't be placed if the length of the song isn't known
+	if t.Loop.Active() && t.Progress.Total > 0 && barW > 0 {
+		// the loop is marked by brackets around the section that repeats,
+		// which are reversed where the bar is complete so they stand out
+		markers := [...]struct {
+			pos time.Duration
+			r   rune
+		}{{t.Loop.A, '['}, {t.Loop.B, ']'}}
+		for _, m := range markers {
+			ratio := float64(m.pos) / float64(t.Progress.Total)
+			x := util.Clamp(0, int(ratio*float64(barW)), barW-1)
+
+			style := styleDefault
+			if x < barCompleteW {
+				style = style.Reverse(true)
+			}
+			t.draw(t.barR.Min.Add(image.Pt(dW+1+x, 0)), m.r, style)
+		}
+	}
+
 	t.draw(image.Pt(t.barR.Max.X-dW-1, t.barR.Min.Y), '|', styleDefault)
 	t.drawString(image.Pt(t.barR.Max.X-dW, t.barR.Min.Y), t.barR.Max.X, totalS, styleDefault)
 }
@@ -0,0 +1,21 @@
+package tui
+
+import (
+	"image"
+
+	"github.com/mattn/go-runewidth"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// drawEQOverlay draws the name of the active equalizer preset over the bottom
+// right corner of the query pane while the overlay is visible.
+func (t *tui) drawEQOverlay() {
+	if !t.eqOverlayVisible {
+		return
+	}
+
+	text := " EQ: " + string(t.EQ) + " "
+	x := util.Max(t.queryR.Max.X-runewidth.StringWidth(text), t.queryR.Min.X)
+	t.drawString(image.Pt(x, t.queryR.Max.Y-1), t.queryR.Max.X, text, styleDefault.Reverse(true))
+}
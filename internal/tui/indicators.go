@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"fmt"
 	"image"
+	"strconv"
+	"strings"
 
 	"mtoohey.com/q/internal/protocol"
 
@@ -13,17 +16,33 @@ type runeStylePair struct {
 	s tcell.Style
 }
 
+// volumeRunes are the runes that indicate the volume when it's muted, and when
+// it's in the lower, middle, and upper third of its range.
+type volumeRunes struct {
+	mute, low, medium, high rune
+}
+
 func (t *tui) initIndicatorRunes() {
 	t.shuffleRune = 's'
 	if t.screen.CanDisplay('󰒝', false) {
 		t.shuffleRune = '󰒝'
 	}
 
+	t.nightModeRune = 'z'
+	if t.screen.CanDisplay('󰖔', false) {
+		t.nightModeRune = '󰖔'
+	}
+
 	t.pauseRuneMap = map[protocol.PauseState]rune{false: '>', true: '>'}
 	if t.screen.CanDisplay('󰏤', false) && t.screen.CanDisplay('󰐊', false) {
 		t.pauseRuneMap = map[protocol.PauseState]rune{false: '󰏤', true: '󰐊'}
 	}
 
+	t.stopRune = '#'
+	if t.screen.CanDisplay('󰓛', false) {
+		t.stopRune = '󰓛'
+	}
+
 	t.repeatRuneStyleMap = map[protocol.RepeatState]runeStylePair{
 		protocol.RepeatStateNone:  {'r', styleDim},
 		protocol.RepeatStateQueue: {'r', styleDefault},
@@ -38,6 +57,22 @@ func (t *tui) initIndicatorRunes() {
 			protocol.RepeatStateTrack: {'󰑘', styleDefault},
 		}
 	}
+
+	t.volumeRunes = volumeRunes{'v', 'v', 'v', 'v'}
+	if t.screen.CanDisplay('󰝟', false) && t.screen.CanDisplay('󰕿', false) &&
+		t.screen.CanDisplay('󰖀', false) && t.screen.CanDisplay('󰕾', false) {
+
+		t.volumeRunes = volumeRunes{'󰝟', '󰕿', '󰖀', '󰕾'}
+	}
+}
+
+func (t *tui) drawNightMode() {
+	style := styleDim
+	if t.NightMode {
+		style = styleDefault
+	}
+
+	t.draw(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2-15, 0)), t.nightModeRune, style)
 }
 
 func (t *tui) drawShuffle() {
@@ -50,15 +85,68 @@ func (t *tui) drawShuffle() {
 }
 
 func (t *tui) drawPause() {
-	style := styleDefault
-	if t.Pause {
+	r, style := t.pauseRuneMap[t.Pause], styleDefault
+	if t.Stop {
+		r, style = t.stopRune, styleDim
+	} else if t.Pause {
 		style = styleDim
 	}
 
-	t.draw(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2, 0)), t.pauseRuneMap[t.Pause], style)
+	t.draw(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2, 0)), r, style)
 }
 
 func (t *tui) drawRepeat() {
 	pair := t.repeatRuneStyleMap[t.Repeat]
 	t.draw(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2+5, 0)), pair.r, pair.s)
 }
+
+func (t *tui) drawVolume() {
+	r, style := t.volumeRunes.high, styleDefault
+	switch {
+	case bool(t.Mute):
+		r, style = t.volumeRunes.mute, styleDim
+	case t.Volume < protocol.MaxVolume/3:
+		r = t.volumeRunes.low
+	case t.Volume < protocol.MaxVolume*2/3:
+		r = t.volumeRunes.medium
+	}
+
+	pt := t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2+10, 0))
+	t.draw(pt, r, style)
+	// padded so that a shorter percentage overwrites a longer one
+	t.drawString(pt.Add(image.Pt(2, 0)), t.progressR.Max.X, fmt.Sprintf("%3d%%", t.Volume), style)
+}
+
+func (t *tui) drawSpeed() {
+	const w = 5 // enough for "1.25x"
+
+	var text string
+	if t.Speed != 1 {
+		text = strconv.FormatFloat(float64(t.Speed), 'f', -1, 64) + "x"
+	}
+	// padded so that a shorter speed overwrites a longer one
+	text = fmt.Sprintf("%*s", w, text)
+
+	t.drawString(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2-7-w, 0)), t.progressR.Max.X, text, styleDefault)
+}
+
+func (t *tui) drawChannels() {
+	const w = 14 // enough for "mono swap R100"
+
+	var parts []string
+	if t.Mono {
+		parts = append(parts, "mono")
+	}
+	if t.SwapChannels {
+		parts = append(parts, "swap")
+	}
+	if t.Balance < 0 {
+		parts = append(parts, fmt.Sprintf("L%d", -t.Balance))
+	} else if t.Balance > 0 {
+		parts = append(parts, fmt.Sprintf("R%d", t.Balance))
+	}
+	// padded so that shorter text overwrites longer text
+	text := fmt.Sprintf("%-*s", w, strings.Join(parts, " "))
+
+	t.drawString(t.progressR.Min.Add(image.Pt(t.progressR.Dx()/2+18, 0)), t.progressR.Max.X, text, styleDim)
+}
@@ -36,9 +36,13 @@ func (t *tui) drawInfoAndProgress() {
 	lineR := image.Rect(t.progressR.Min.X, t.progressR.Min.Y, t.progressR.Max.X, t.progressR.Min.Y+1)
 	// clear top row, since point draws won't
 	t.clear(lineR)
+	t.drawNightMode()
+	t.drawSpeed()
 	t.drawShuffle()
 	t.drawPause()
 	t.drawRepeat()
+	t.drawVolume()
+	t.drawChannels()
 
 	t.barR = lineR.Add(image.Pt(0, 1))
 	t.drawBar()
@@ -53,6 +57,7 @@ const (
 	modeNormal mode = iota
 	modeInsert
 	modeSelect
+	modeLyrics
 )
 
 func (m mode) String() string {
@@ -63,6 +68,8 @@ func (m mode) String() string {
 		return " INS "
 	case modeSelect:
 		return " SEL "
+	case modeLyrics:
+		return " LYR "
 	default:
 		panic(fmt.Sprintf(`invalid mode "%d"`, m))
 	}
@@ -77,6 +84,8 @@ func (m mode) Style() tcell.Style {
 		bg = tcell.ColorGreen
 	case modeSelect:
 		bg = tcell.ColorYellow
+	case modeLyrics:
+		bg = tcell.ColorFuchsia
 	default:
 		panic(fmt.Sprintf(`invalid mode "%d"`, m))
 	}
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"image"
+
+	"mtoohey.com/q/internal/util"
+)
+
+// drawLyrics draws the lyrics pane, which replaces the query pane while in
+// lyrics mode.
+func (t *tui) drawLyrics() {
+	t.screen.HideCursor()
+	defer t.drawEQOverlay()
+
+	if len(t.Lyrics.Lines) == 0 {
+		t.centeredString(t.queryR, "no lyrics")
+		return
+	}
+
+	// keep the active line in the middle of the pane, unless it was scrolled
+	// manually since the active line last changed
+	if line := t.Lyrics.Line(t.Progress.Current); line != t.lyricsLineIdx {
+		t.lyricsLineIdx = line
+		t.lyricsScrollIdx = line - t.queryR.Dy()/2
+	}
+	t.lyricsScrollIdx = util.Clamp(0, t.lyricsScrollIdx, len(t.Lyrics.Lines)-t.queryR.Dy())
+
+	i := 0
+	for ; i < t.queryR.Dy() && i+t.lyricsScrollIdx < len(t.Lyrics.Lines); i++ {
+		style := styleDefault
+		if t.Lyrics.Synced {
+			style = styleDim
+			if i+t.lyricsScrollIdx == t.lyricsLineIdx {
+				style = styleDefault.Bold(true)
+			}
+		}
+
+		t.draw(t.queryR.Min.Add(image.Pt(0, i)), ' ', styleDefault)
+
+		x := t.drawString(t.queryR.Min.Add(image.Pt(1, i)), t.queryR.Max.X-1, t.Lyrics.Lines[i+t.lyricsScrollIdx].Text, style)
+		t.clear(image.Rect(x, t.queryR.Min.Y+i, t.queryR.Max.X, t.queryR.Min.Y+i+1))
+	}
+	t.clear(image.Rect(t.queryR.Min.X, t.queryR.Min.Y+i, t.queryR.Max.X, t.queryR.Max.Y))
+}
+
+func (t *tui) lyricsScrollTo(to int) {
+	t.lyricsScrollIdx = to
+	t.drawLyrics()
+}
+
+func (t *tui) lyricsScroll(by int) {
+	t.lyricsScrollTo(t.lyricsScrollIdx + by)
+}
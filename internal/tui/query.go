@@ -11,6 +11,13 @@ import (
 )
 
 func (t *tui) drawQuery() {
+	// the lyrics pane takes the place of the query pane in lyrics mode
+	if t.mode == modeLyrics {
+		t.drawLyrics()
+		return
+	}
+	defer t.drawEQOverlay()
+
 	if t.mode == modeInsert {
 		t.screen.ShowCursor(t.queryR.Min.X+1+runewidth.StringWidth(t.queryString[:t.queryMouseIdx]), t.queryR.Min.Y)
 		t.screen.SetCursorStyle(tcell.CursorStyleSteadyBar)
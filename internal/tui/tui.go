@@ -16,6 +16,14 @@ import (
 	"github.com/gdamore/tcell/v2"
 )
 
+// volumeStep is the number of percentage points by which the volume is changed
+// with each key press.
+const volumeStep = 5
+
+// balanceStep is the number of percentage points by which the balance is
+// changed with each key press.
+const balanceStep = 10
+
 type tui struct {
 	// constants
 	Cmd
@@ -31,8 +39,11 @@ type tui struct {
 
 	// ui state
 	shuffleRune        rune
+	nightModeRune      rune
 	pauseRuneMap       map[protocol.PauseState]rune
+	stopRune           rune
 	repeatRuneStyleMap map[protocol.RepeatState]runeStylePair
+	volumeRunes        volumeRunes
 
 	topR      image.Rectangle
 	bottomR   image.Rectangle
@@ -46,6 +57,9 @@ type tui struct {
 	errorR    image.Rectangle
 
 	visibleErr error
+	// eqOverlayVisible indicates whether the active equalizer preset is
+	// being shown, which happens for a short time after it changes.
+	eqOverlayVisible bool
 
 	mode mode
 
@@ -58,6 +72,11 @@ type tui struct {
 	queryScrollIdx int
 	queryString    string
 	queryResults   protocol.QueryResults
+
+	// lyricsLineIdx is the index of the active line of the lyrics when the
+	// lyrics pane was last drawn.
+	lyricsLineIdx   int
+	lyricsScrollIdx int
 }
 
 // newTUI creates a new tui. conn should not yet have had its initial message
@@ -72,6 +91,8 @@ func newTUI(cmd Cmd, g cmd.Globals, logger *log.Logger, conn protocol.Conn) (*tu
 		Globals: g,
 		logger:  logger,
 		conn:    conn,
+
+		lyricsLineIdx: -1,
 	}
 
 	m, err := conn.Receive()
@@ -224,6 +245,37 @@ func (t *tui) loop() (err error) {
 		}()
 	}
 
+	var eqOverlayTimeoutCancel chan struct{}
+	defer func() {
+		if eqOverlayTimeoutCancel != nil {
+			close(eqOverlayTimeoutCancel)
+			eqOverlayTimeoutCancel = nil
+		}
+	}()
+	hideEQOverlayCh := make(chan struct{})
+	showEQOverlay := func() {
+		// if there's an existing timeout routine running, stop it
+		if eqOverlayTimeoutCancel != nil {
+			close(eqOverlayTimeoutCancel)
+		}
+
+		t.eqOverlayVisible = true
+		t.drawEQOverlay()
+
+		// start a new timeout routine
+		eqOverlayTimeoutCancel = make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case <-time.After(time.Second * 2):
+				hideEQOverlayCh <- struct{}{}
+			case <-eqOverlayTimeoutCancel:
+			}
+		}()
+	}
+
 	for {
 		select {
 		case err := <-serverErrorCh:
@@ -234,6 +286,11 @@ func (t *tui) loop() (err error) {
 			errTimeoutCancel = nil
 			t.drawError()
 
+		case <-hideEQOverlayCh:
+			t.eqOverlayVisible = false
+			eqOverlayTimeoutCancel = nil
+			t.drawQuery()
+
 		case m := <-serverMessageCh:
 			switch m := m.(type) {
 			case protocol.Error:
@@ -242,6 +299,9 @@ func (t *tui) loop() (err error) {
 			case protocol.ProgressState:
 				t.Progress = m
 				t.drawBar()
+				if t.mode == modeLyrics && t.Lyrics.Line(m.Current) != t.lyricsLineIdx {
+					t.drawLyrics()
+				}
 
 			case protocol.NowPlayingState:
 				old := t.NowPlaying.Cover
@@ -259,6 +319,14 @@ func (t *tui) loop() (err error) {
 				t.Pause = m
 				t.drawPause()
 
+			case protocol.StopState:
+				t.Stop = m
+				t.drawPause()
+
+			case protocol.LoopState:
+				t.Loop = m
+				t.drawBar()
+
 			case protocol.ShuffleState:
 				t.Shuffle = m
 				t.drawShuffle()
@@ -267,6 +335,55 @@ func (t *tui) loop() (err error) {
 				t.Repeat = m
 				t.drawRepeat()
 
+			case protocol.ReplayGainMode:
+				t.ReplayGain = m
+
+			case protocol.CrossfadeState:
+				t.Crossfade = m
+
+			case protocol.VolumeState:
+				t.Volume = m
+				t.drawVolume()
+
+			case protocol.MuteState:
+				t.Mute = m
+				t.drawVolume()
+
+			case protocol.SpeedState:
+				t.Speed = m
+				t.drawSpeed()
+
+			case protocol.PreservePitchState:
+				t.PreservePitch = m
+
+			case protocol.BalanceState:
+				t.Balance = m
+				t.drawChannels()
+
+			case protocol.MonoState:
+				t.Mono = m
+				t.drawChannels()
+
+			case protocol.SwapChannelsState:
+				t.SwapChannels = m
+				t.drawChannels()
+
+			case protocol.NightModeState:
+				t.NightMode = m
+				t.drawNightMode()
+
+			case protocol.EQState:
+				t.EQ = m
+				showEQOverlay()
+
+			case protocol.LyricsState:
+				t.Lyrics = m
+				t.lyricsLineIdx = -1
+				t.lyricsScrollIdx = 0
+				if t.mode == modeLyrics {
+					t.drawLyrics()
+				}
+
 			case protocol.QueryResults:
 				t.queryResults = m
 				t.drawQuery()
@@ -373,6 +490,51 @@ func (t *tui) loop() (err error) {
 						case 'R':
 							err = t.conn.Send(t.Repeat.Prev())
 
+						case 'c':
+							err = t.conn.Send(t.Crossfade.Next())
+
+						case 'C':
+							err = t.conn.Send(t.Crossfade.Prev())
+
+						case '+', '=':
+							err = t.conn.Send(t.Volume.Add(volumeStep))
+
+						case '-':
+							err = t.conn.Send(t.Volume.Add(-volumeStep))
+
+						case 'm':
+							err = t.conn.Send(!t.Mute)
+
+						case '>':
+							err = t.conn.Send(t.Speed.Next())
+
+						case '<':
+							err = t.conn.Send(t.Speed.Prev())
+
+						case 't':
+							err = t.conn.Send(!t.PreservePitch)
+
+						case '[':
+							err = t.conn.Send(t.Balance.Add(-balanceStep))
+
+						case ']':
+							err = t.conn.Send(t.Balance.Add(balanceStep))
+
+						case 'o':
+							err = t.conn.Send(!t.Mono)
+
+						case 'w':
+							err = t.conn.Send(!t.SwapChannels)
+
+						case 'z':
+							err = t.conn.Send(!t.NightMode)
+
+						case 'e':
+							err = t.conn.Send(t.EQ.Next(t.EQPresets))
+
+						case 'E':
+							err = t.conn.Send(t.EQ.Prev(t.EQPresets))
+
 						case 'n':
 							err = t.conn.Send(protocol.Skip(1))
 
@@ -384,6 +546,11 @@ func (t *tui) loop() (err error) {
 							t.drawMode()
 							t.drawQuery()
 
+						case 'L':
+							t.mode = modeLyrics
+							t.drawMode()
+							t.drawLyrics()
+
 						case 'p':
 							if t.clipboardPath == "" {
 								// TODO: surface a warning or error here to
@@ -608,6 +775,67 @@ func (t *tui) loop() (err error) {
 						}
 					}
 
+				case modeLyrics:
+					switch ev.Key() {
+					case tcell.KeyCtrlC, tcell.KeyESC:
+						t.mode = modeNormal
+						t.drawMode()
+						t.drawQuery()
+
+					case tcell.KeyLeft:
+						err = t.conn.Send(protocol.Seek(t.Progress.Current - time.Second*5))
+
+					case tcell.KeyRight:
+						err = t.conn.Send(protocol.Seek(t.Progress.Current + time.Second*5))
+
+					case tcell.KeyUp:
+						t.lyricsScroll(-1)
+
+					case tcell.KeyDown:
+						t.lyricsScroll(1)
+
+					case tcell.KeyCtrlU, tcell.KeyCtrlB:
+						t.lyricsScroll(-10)
+
+					case tcell.KeyCtrlD, tcell.KeyCtrlF:
+						t.lyricsScroll(10)
+
+					case tcell.KeyPgUp:
+						t.lyricsScroll(-t.queryR.Dy())
+
+					case tcell.KeyPgDn:
+						t.lyricsScroll(t.queryR.Dy())
+
+					case tcell.KeyHome:
+						t.lyricsScrollTo(0)
+
+					case tcell.KeyEnd:
+						t.lyricsScrollTo(math.MaxInt)
+
+					case tcell.KeyRune:
+						switch ev.Rune() {
+						case 'L':
+							t.mode = modeNormal
+							t.drawMode()
+							t.drawQuery()
+
+						case ' ':
+							err = t.conn.Send(protocol.PauseState(!t.Pause))
+
+						case 'k':
+							t.lyricsScroll(-1)
+
+						case 'j':
+							t.lyricsScroll(1)
+
+						case 'g':
+							t.lyricsScrollTo(0)
+
+						case 'G':
+							t.lyricsScrollTo(math.MaxInt)
+						}
+					}
+
 				default:
 					panic(fmt.Sprintf(`invalid mode "%d"`, t.mode))
 				}
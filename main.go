@@ -2,7 +2,9 @@ package main
 
 import (
 	"os"
+	"strings"
 
+	"mtoohey.com/q/internal/analysis"
 	"mtoohey.com/q/internal/cmd"
 	"mtoohey.com/q/internal/remote"
 	"mtoohey.com/q/internal/server"
@@ -17,12 +19,6 @@ import (
 
 // TODO(tui): smoother progress display
 
-// TODO(server): volume normalization
-
-// TODO(server): song gap normalization
-
-// TODO(server/tui): unsynchronized and synchronized lyrics
-
 // TODO(tui): add mouse support
 
 // TODO: proper docs (manpage, details in README, etc.)
@@ -47,23 +43,26 @@ import (
 
 type cli struct {
 	cmd.Globals
-	Remote  remote.Cmd `cmd:"" aliases:"r" help:"Communicate with a server."`
-	Server  server.Cmd `cmd:"" aliases:"s" help:"Start a server in the background."`
-	Support track.Cmd  `cmd:"" aliases:"p" help:"Show info about supported formats."`
-	TUI     tui.Cmd    `cmd:"" default:"withargs" aliases:"t" help:"Start an interactive TUI."`
+	Analyze analysis.Cmd `cmd:"" aliases:"a" help:"Analyze the loudness and silence of songs."`
+	Remote  remote.Cmd   `cmd:"" aliases:"r" help:"Communicate with a server."`
+	Server  server.Cmd   `cmd:"" aliases:"s" help:"Start a server in the background."`
+	Support track.Cmd    `cmd:"" aliases:"p" help:"Show info about supported formats."`
+	TUI     tui.Cmd      `cmd:"" default:"withargs" aliases:"t" help:"Start an interactive TUI."`
 }
 
 func main() {
 	var flags cli
-	parser := kong.Parse(&flags, append(
+	parser := kong.Must(&flags, append(
 		cmd.TypeMappers,
 		kong.Description("A terminal music player."),
+		kong.Vars{"default_cover_names": strings.Join(track.DefaultCoverNames, ",")},
 	)...)
 
 	globalsArgs, err := cmd.LoadGlobalsConfig()
 	parser.FatalIfErrorf(err)
 
-	ctx, err := parser.Parse(append(globalsArgs, os.Args[1:]...))
+	args := remote.EscapeNegativeNumbers(append(globalsArgs, os.Args[1:]...))
+	ctx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
 	parser.FatalIfErrorf(ctx.Run(flags.Globals))
 }